@@ -23,7 +23,13 @@ type admissionGenerator struct {
 func CreateAdmissionGenerator(apis *APIs, filename string, projectRootPath string, outputBase string) generator.Generator {
 	admissionKinds := []string{}
 	// filter out those resources created w/ `--admission-controller` flag
-	for _, group := range apis.Groups {
+	groups := []*APIGroup{}
+	for _, a := range append([]*APIs{apis}, apis.Aggregated...) {
+		for _, group := range a.Groups {
+			groups = append(groups, group)
+		}
+	}
+	for _, group := range groups {
 		for _, version := range group.Versions {
 			for _, resource := range version.Resources {
 				resourceAdmissionControllerPkg := filepath.Join(outputBase, projectRootPath, "plugin", "admission", strings.ToLower(resource.Kind))
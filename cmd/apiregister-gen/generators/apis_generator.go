@@ -55,6 +55,9 @@ func (d *apiGenerator) Imports(c *generator.Context) []string {
 		imports = append(imports, fmt.Sprintf(
 			"_ \"%s/install\" // Install the %s group", group.Pkg.Path, group.Group))
 	}
+	for _, apis := range d.apis.Aggregated {
+		imports = append(imports, fmt.Sprintf("%s \"%s\"", apis.Alias, apis.Package))
+	}
 
 	return imports
 }
@@ -75,6 +78,9 @@ var (
 	{{ range $version := $group.Versions -}}
 		{{ $group.Group }}{{ $version.Version }}.AddToScheme,
 	{{ end -}}
+{{ end -}}
+{{ range $apis := .Aggregated -}}
+		{{ $apis.Alias }}.AddToScheme,
 {{ end -}}
 	}
 	AddToScheme = localSchemeBuilder.AddToScheme
@@ -82,6 +88,20 @@ var (
 
 // GetAllApiBuilders returns all known APIGroupBuilders
 // so they can be registered with the apiserver
+{{ if .Aggregated -}}
+// The groups of the aggregated apis packages are returned after the groups of this package
+func GetAllApiBuilders() []*builders.APIGroupBuilder {
+	apiBuilders := []*builders.APIGroupBuilder{
+		{{ range $group := .Groups -}}
+		Get{{ $group.GroupTitle }}APIBuilder(),
+		{{ end -}}
+	}
+	{{ range $apis := .Aggregated -}}
+	apiBuilders = append(apiBuilders, {{ $apis.Alias }}.GetAllApiBuilders()...)
+	{{ end -}}
+	return apiBuilders
+}
+{{ else -}}
 func GetAllApiBuilders() []*builders.APIGroupBuilder {
 	return []*builders.APIGroupBuilder{
 		{{ range $group := .Groups -}}
@@ -89,6 +109,7 @@ func GetAllApiBuilders() []*builders.APIGroupBuilder {
 		{{ end -}}
 	}
 }
+{{ end -}}
 
 {{ range $group := .Groups -}}
 var {{ $group.Group }}ApiGroup = builders.NewApiGroupBuilder(
@@ -0,0 +1,172 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/gengo/types"
+)
+
+// StructDefaults are the defaults of the "+default=" comment tags on the fields of a versioned
+// struct, set by its generated SetDefaults_<Name> function
+type StructDefaults struct {
+	// Name is the name of the struct - e.g. PeachesCastleSpec
+	Name string
+	// Statements set the defaults of the unset fields
+	Statements []string
+	// Fields are the defaults served in the OpenAPI definition of the struct
+	Fields []*FieldDefault
+}
+
+// FieldDefault is the default of a field parsed from a "+default=<json value>" comment
+type FieldDefault struct {
+	// JSONName is the name of the field in its json serialization - e.g. replicas
+	JSONName string
+	// Value is the quoted json value of the default
+	Value string
+}
+
+// ParseDefaults parses the "+default=" comment tags on the fields of the structs of the versioned
+// package. The nested structs are defaulted through their own SetDefaults function, which the
+// defaulter-gen calls from the SetObjectDefaults function of the resource.
+func (apiversion *APIVersion) ParseDefaults() {
+	if apiversion.Pkg == nil {
+		return
+	}
+	names := []string{}
+	for name := range apiversion.Pkg.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := apiversion.Pkg.Types[name]
+		if t.Kind != types.Struct {
+			continue
+		}
+		d := &StructDefaults{Name: t.Name.Name}
+		for _, member := range t.Members {
+			value := Comments(trimComments(member.CommentLines)).GetTag("default", "=")
+			if len(value) == 0 {
+				continue
+			}
+			statement, usesJSON := parseFieldDefault(t, member, value)
+			apiversion.DefaultsUseJSON = apiversion.DefaultsUseJSON || usesJSON
+			d.Statements = append(d.Statements, statement)
+			d.Fields = append(d.Fields, &FieldDefault{JSONName: jsonName(member), Value: strconv.Quote(value)})
+		}
+		if len(d.Statements) > 0 {
+			apiversion.Defaults = append(apiversion.Defaults, d)
+		}
+	}
+}
+
+// parseFieldDefault returns the statement setting the default of a field of the versioned type t
+// when it is unset, and whether the statement decodes the default from json
+func parseFieldDefault(t *types.Type, member types.Member, value string) (string, bool) {
+	fail := func(format string, args ...interface{}) {
+		fieldError(t, member.Name, "Invalid default marker: %s", fmt.Sprintf(format, args...))
+	}
+	if member.Embedded {
+		fail("+default is not supported on embedded fields")
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		fail("+default=%s is not a json value: %v", value, err)
+	}
+
+	field := "obj." + member.Name
+	fieldType := member.Type
+	pointer := fieldType.Kind == types.Pointer
+	if pointer {
+		fieldType = fieldType.Elem
+	}
+	underlying := fieldType
+	for underlying.Kind == types.Alias {
+		underlying = underlying.Underlying
+	}
+
+	// Builtin values of the versioned package are set from a literal
+	local := fieldType.Kind == types.Builtin || fieldType.Name.Package == t.Name.Package
+	if underlying.Kind == types.Builtin && local {
+		literal, zero := "", ""
+		switch name := underlying.Name.Name; {
+		case numericTypes[name]:
+			n, ok := decoded.(float64)
+			if !ok {
+				fail("+default=%s requires a number on a numeric field", value)
+			}
+			literal, zero = value, "0"
+			if !strings.HasPrefix(name, "float") {
+				if n != math.Trunc(n) || (strings.HasPrefix(name, "uint") && n < 0) {
+					fail("+default=%s requires an integer on an %s field", value, name)
+				}
+				literal = strconv.FormatInt(int64(n), 10)
+			}
+		case name == "string":
+			s, ok := decoded.(string)
+			if !ok {
+				fail("+default=%s requires a json string on a string field", value)
+			}
+			literal, zero = strconv.Quote(s), `""`
+		case name == "bool":
+			b, ok := decoded.(bool)
+			if !ok {
+				fail("+default=%s requires true or false on a bool field", value)
+			}
+			if !pointer {
+				fail("+default requires a *bool field, false can't be told apart from unset")
+			}
+			literal = strconv.FormatBool(b)
+		default:
+			fail("+default is not supported on %s fields", name)
+		}
+		if pointer {
+			return fmt.Sprintf("if %s == nil {\n\t\tv := %s(%s)\n\t\t%s = &v\n\t}",
+				field, fieldType.Name.Name, literal, field), false
+		}
+		return fmt.Sprintf("if %s == %s {\n\t\t%s = %s\n\t}", field, zero, field, literal), false
+	}
+
+	// Other values are decoded from json when the field is unset
+	unset := ""
+	switch {
+	case pointer:
+		unset = field + " == nil"
+	case underlying.Kind == types.Slice:
+		if _, ok := decoded.([]interface{}); !ok {
+			fail("+default=%s requires a json array on a slice field", value)
+		}
+		unset = fmt.Sprintf("len(%s) == 0", field)
+	case underlying.Kind == types.Map:
+		if _, ok := decoded.(map[string]interface{}); !ok {
+			fail("+default=%s requires a json object on a map field", value)
+		}
+		unset = fmt.Sprintf("len(%s) == 0", field)
+	default:
+		fail("+default requires a pointer, slice or map field for %s values, the field can't be told apart from unset",
+			fieldType.Name.Name)
+	}
+	return fmt.Sprintf("if %s {\n\t\tif err := json.Unmarshal([]byte(%s), &%s); err != nil {\n\t\t\t// TODO: Propagate error up\n\t\t\tpanic(err)\n\t\t}\n\t}",
+		unset, strconv.Quote(value), field), true
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/gengo/types"
+)
+
+// Diagnostic is an invalid comment tag found while parsing the apis packages
+type Diagnostic struct {
+	// Position is the file:line of the declaration carrying the comment tag, empty if unknown
+	Position string
+	// Type is the name of the type carrying the comment tag, empty for package errors
+	Type string
+	// Field is the name of the field carrying the comment tag, empty for type comment tags
+	Field string
+	// Message describes the error
+	Message string
+
+	// file and line order the diagnostics
+	file string
+	line int
+}
+
+func (d *Diagnostic) String() string {
+	elems := []string{}
+	if len(d.Position) > 0 {
+		elems = append(elems, d.Position)
+	}
+	switch {
+	case len(d.Field) > 0:
+		elems = append(elems, d.Type+"."+d.Field)
+	case len(d.Type) > 0:
+		elems = append(elems, d.Type)
+	}
+	return strings.Join(append(elems, d.Message), ": ")
+}
+
+// diagnostics are the invalid comment tags found while parsing, the parser records every error
+// and the generator reports them together instead of stopping at the first one
+var diagnostics = &diagnosticList{}
+
+type diagnosticList struct {
+	universe types.Universe
+	// positions are the positions of the type and field declarations of the parsed packages by
+	// package path, then by type name or type and field names - e.g. FooSpec.Replicas
+	positions map[string]map[string]token.Position
+	list      []*Diagnostic
+}
+
+// Diagnostics returns the invalid comment tags found by the parser, sorted by position
+func Diagnostics() []*Diagnostic {
+	list := append([]*Diagnostic{}, diagnostics.list...)
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].file != list[j].file {
+			return list[i].file < list[j].file
+		}
+		return list[i].line < list[j].line
+	})
+	return list
+}
+
+// typeError records an invalid comment tag on the type t
+func typeError(t *types.Type, format string, args ...interface{}) {
+	diagnostics.add(t.Name.Package, t.Name.Name, "", fmt.Sprintf(format, args...))
+}
+
+// fieldError records an invalid comment tag on the field of the type t
+func fieldError(t *types.Type, field string, format string, args ...interface{}) {
+	diagnostics.add(t.Name.Package, t.Name.Name, field, fmt.Sprintf(format, args...))
+}
+
+// packageError records an error of the package, located at its doc.go file
+func packageError(pkg string, format string, args ...interface{}) {
+	d := &Diagnostic{Message: fmt.Sprintf(format, args...)}
+	if p := diagnostics.universe[pkg]; p != nil && len(p.SourcePath) > 0 {
+		d.file = relativePath(filepath.Join(p.SourcePath, "doc.go"))
+		d.Position = d.file
+	}
+	diagnostics.list = append(diagnostics.list, d)
+}
+
+func (l *diagnosticList) add(pkg, typeName, field, message string) {
+	d := &Diagnostic{Type: typeName, Field: field, Message: message}
+	key := typeName
+	if len(field) > 0 {
+		key = typeName + "." + field
+	}
+	if position, found := l.positionsOf(pkg)[key]; found {
+		d.file, d.line = relativePath(position.Filename), position.Line
+		d.Position = fmt.Sprintf("%s:%d", d.file, d.line)
+	}
+	l.list = append(l.list, d)
+}
+
+// positionsOf returns the positions of the type and field declarations of the package, parsed
+// from its sources the first time an error is recorded on it
+func (l *diagnosticList) positionsOf(pkg string) map[string]token.Position {
+	if positions, found := l.positions[pkg]; found {
+		return positions
+	}
+	if l.positions == nil {
+		l.positions = map[string]map[string]token.Position{}
+	}
+	positions := map[string]token.Position{}
+	l.positions[pkg] = positions
+
+	p := l.universe[pkg]
+	if p == nil || len(p.SourcePath) == 0 {
+		return positions
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, p.SourcePath, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return positions
+	}
+	for _, astPkg := range pkgs {
+		for _, file := range astPkg.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				spec, ok := n.(*ast.TypeSpec)
+				if !ok {
+					return true
+				}
+				positions[spec.Name.Name] = fset.Position(spec.Pos())
+				if s, ok := spec.Type.(*ast.StructType); ok {
+					for _, f := range s.Fields.List {
+						for _, name := range fieldNames(f) {
+							positions[spec.Name.Name+"."+name] = fset.Position(f.Pos())
+						}
+					}
+				}
+				return false
+			})
+		}
+	}
+	return positions
+}
+
+// fieldNames returns the names of the field declaration, embedded fields are named by their type
+func fieldNames(f *ast.Field) []string {
+	names := []string{}
+	for _, name := range f.Names {
+		names = append(names, name.Name)
+	}
+	if len(names) > 0 {
+		return names
+	}
+	t := f.Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	switch t := t.(type) {
+	case *ast.Ident:
+		names = append(names, t.Name)
+	case *ast.SelectorExpr:
+		names = append(names, t.Sel.Name)
+	}
+	return names
+}
+
+// relativePath returns the path relative to the working directory if it is below it
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
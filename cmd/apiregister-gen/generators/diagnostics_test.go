@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/gengo/args"
+)
+
+const testdata = "sigs.k8s.io/apiserver-builder-alpha/cmd/apiregister-gen/generators/testdata"
+
+// generate runs the generator on the apis packages of the fixtures and returns the files it wrote
+func generate(t *testing.T, customArgs *CustomArgs, fixtures ...string) ([]string, []*Diagnostic) {
+	outputBase, err := ioutil.TempDir("", "apiregister-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outputBase)
+
+	arguments := &args.GeneratorArgs{
+		OutputBase:         outputBase,
+		OutputFileBaseName: "zz_generated.api.register",
+		GeneratedBuildTag:  "ignore_autogenerated",
+		CustomArgs:         customArgs,
+	}
+	for _, fixture := range fixtures {
+		arguments.InputDirs = append(arguments.InputDirs, testdata+"/"+fixture+"/apis/...")
+	}
+	diagnostics = &diagnosticList{}
+	g := Gen{}
+	if err := g.Execute(arguments); err != nil {
+		t.Fatalf("Execute() failed: %v", err)
+	}
+
+	files := []string{}
+	err = filepath.Walk(outputBase, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, strings.TrimPrefix(path, outputBase+"/"))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files, Diagnostics()
+}
+
+func TestDiagnostics(t *testing.T) {
+	files, diagnostics := generate(t, &CustomArgs{}, "invalid")
+	if len(files) > 0 {
+		t.Errorf("generated %v from invalid comment tags, want no files", files)
+	}
+
+	// Every invalid comment tag is reported at the file:line of its declaration
+	types := filepath.Join("testdata", "invalid", "apis", "widgets", "v1", "widget_types.go")
+	want := []string{
+		types + ":29: Widget: +printcolumn: type must be one of integer, number, string, boolean or date. Got string: [name=Size,type=size,JSONPath=.spec.size]",
+		types + ":39: WidgetSpec.Size: Invalid validation marker: +validation:Minimum=small is not a valid int32 value: invalid syntax",
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("Diagnostics() = %v, want %d diagnostics", diagnostics, len(want))
+	}
+	for i, d := range diagnostics {
+		if d.String() != want[i] {
+			t.Errorf("Diagnostics()[%d] = %q, want %q", i, d, want[i])
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	files, diagnostics := generate(t, &CustomArgs{Lint: true}, "valid")
+	if len(diagnostics) > 0 {
+		t.Errorf("Diagnostics() = %v, want none", diagnostics)
+	}
+	if len(files) > 0 {
+		t.Errorf("generated %v with --lint, want no files", files)
+	}
+
+	_, diagnostics = generate(t, &CustomArgs{Lint: true}, "invalid")
+	if len(diagnostics) != 2 {
+		t.Errorf("Diagnostics() = %v, want the invalid comment tags reported with --lint", diagnostics)
+	}
+
+	// The same input is generated without --lint
+	files, diagnostics = generate(t, &CustomArgs{}, "valid")
+	if len(diagnostics) > 0 {
+		t.Errorf("Diagnostics() = %v, want none", diagnostics)
+	}
+	if len(files) == 0 {
+		t.Errorf("generated no files without --lint")
+	}
+}
+
+func TestDuplicateGroups(t *testing.T) {
+	files, diagnostics := generate(t, &CustomArgs{APIsPackage: testdata + "/valid/apis"}, "valid", "other")
+	if len(files) > 0 {
+		t.Errorf("generated %v from groups found in multiple apis packages, want no files", files)
+	}
+	want := filepath.Join("testdata", "valid", "apis", "widgets", "doc.go") +
+		": Found group widgets in multiple apis packages: " + testdata + "/other/apis and " + testdata + "/valid/apis"
+	if len(diagnostics) != 1 || diagnostics[0].String() != want {
+		t.Errorf("Diagnostics() = %v, want %q", diagnostics, want)
+	}
+}
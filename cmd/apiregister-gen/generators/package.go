@@ -21,19 +21,22 @@ import (
 	"path/filepath"
 	"strings"
 
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/gengo/args"
 	"k8s.io/gengo/generator"
 	"k8s.io/gengo/namer"
 	"k8s.io/gengo/types"
 	"k8s.io/klog"
-
-	"github.com/pkg/errors"
 )
 
 // CustomArgs is used tby the go2idl framework to pass args specific to this
 // generator.
-type CustomArgs struct{}
+type CustomArgs struct {
+	// APIsPackage is the apis package aggregating the groups of every apis package, required
+	// when the input contains several apis packages
+	APIsPackage string
+	// Lint only validates the comment tags of the input without generating code
+	Lint bool
+}
 
 type Gen struct {
 	p []generator.Package
@@ -60,30 +63,6 @@ func (g *Gen) NameSystems() namer.NameSystems {
 	}
 }
 
-func (g *Gen) ParsePackages(context *generator.Context, arguments *args.GeneratorArgs) (sets.String, sets.String, string, string) {
-	versionedPkgs := sets.NewString()
-	unversionedPkgs := sets.NewString()
-	mainPkg := ""
-	apisPkg := ""
-	for _, o := range context.Order {
-		if IsAPIResource(o) {
-			versioned := o.Name.Package
-			versionedPkgs.Insert(versioned)
-			unversioned := filepath.Dir(versioned)
-			unversionedPkgs.Insert(unversioned)
-
-			if apis := filepath.Dir(unversioned); apis != apisPkg && len(apisPkg) > 0 {
-				panic(errors.Errorf(
-					"Found multiple apis directory paths: %v and %v", apisPkg, apis))
-			} else {
-				apisPkg = apis
-				mainPkg = filepath.Dir(apisPkg)
-			}
-		}
-	}
-	return versionedPkgs, unversionedPkgs, apisPkg, mainPkg
-}
-
 func (g *Gen) Packages(context *generator.Context, arguments *args.GeneratorArgs) generator.Packages {
 	boilerplate, err := arguments.LoadGoBoilerplate()
 	if err != nil {
@@ -93,27 +72,37 @@ func (g *Gen) Packages(context *generator.Context, arguments *args.GeneratorArgs
 	g.p = generator.Packages{}
 
 	b := NewAPIsBuilder(context, arguments)
-	for _, apigroup := range b.APIs.Groups {
-		for _, apiversion := range apigroup.Versions {
-			factory := &packageFactory{apiversion.Pkg.Path, arguments, boilerplate}
-			// Add generators for versioned types
-			gen := CreateVersionedGenerator(apiversion, apigroup, arguments.OutputFileBaseName)
+	if len(diagnostics.list) > 0 {
+		// Nothing is generated from invalid comment tags, the caller reports the Diagnostics
+		return g.p
+	}
+	if customArgs, ok := arguments.CustomArgs.(*CustomArgs); ok && customArgs.Lint {
+		return g.p
+	}
+	for _, apisPkg := range b.APIsPkgs.List() {
+		apis := b.Roots[apisPkg]
+		for _, apigroup := range apis.Groups {
+			for _, apiversion := range apigroup.Versions {
+				factory := &packageFactory{apiversion.Pkg.Path, arguments, boilerplate}
+				// Add generators for versioned types
+				gen := CreateVersionedGenerator(apiversion, apigroup, arguments.OutputFileBaseName)
+				g.p = append(g.p, factory.createPackage(gen))
+			}
+
+			factory := &packageFactory{apigroup.Pkg.Path, arguments, boilerplate}
+			gen := CreateUnversionedGenerator(apigroup, arguments.OutputFileBaseName)
 			g.p = append(g.p, factory.createPackage(gen))
-		}
 
-		factory := &packageFactory{apigroup.Pkg.Path, arguments, boilerplate}
-		gen := CreateUnversionedGenerator(apigroup, arguments.OutputFileBaseName)
-		g.p = append(g.p, factory.createPackage(gen))
+			factory = &packageFactory{path.Join(apigroup.Pkg.Path, "install"), arguments, boilerplate}
+			gen = CreateInstallGenerator(apigroup, arguments.OutputFileBaseName)
+			g.p = append(g.p, factory.createPackage(gen))
+		}
 
-		factory = &packageFactory{path.Join(apigroup.Pkg.Path, "install"), arguments, boilerplate}
-		gen = CreateInstallGenerator(apigroup, arguments.OutputFileBaseName)
-		g.p = append(g.p, factory.createPackage(gen))
+		apisFactory := &packageFactory{apis.Pkg.Path, arguments, boilerplate}
+		gen := CreateApisGenerator(apis, arguments.OutputFileBaseName)
+		g.p = append(g.p, apisFactory.createPackage(gen))
 	}
 
-	apisFactory := &packageFactory{b.APIs.Pkg.Path, arguments, boilerplate}
-	gen := CreateApisGenerator(b.APIs, arguments.OutputFileBaseName)
-	g.p = append(g.p, apisFactory.createPackage(gen))
-
 	projectRootPath := filepath.Dir(filepath.Dir(b.APIs.Pkg.Path))
 	admissionFactory := &packageFactory{filepath.Join(projectRootPath, "plugin", "admission", "install"), arguments, boilerplate}
 	admissionGen := CreateAdmissionGenerator(b.APIs, arguments.OutputFileBaseName, projectRootPath, b.arguments.OutputBase)
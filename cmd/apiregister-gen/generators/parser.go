@@ -21,6 +21,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -28,7 +29,6 @@ import (
 	"k8s.io/gengo/args"
 	"k8s.io/gengo/generator"
 	"k8s.io/gengo/types"
-	"k8s.io/klog"
 )
 
 type APIs struct {
@@ -39,6 +39,11 @@ type APIs struct {
 	Pkg     *types.Package
 	// Groups is a list of API groups
 	Groups map[string]*APIGroup
+	// Alias is the name the package is imported as by the package aggregating it
+	Alias string
+	// Aggregated are the other apis packages whose groups are returned by the GetAllApiBuilders
+	// function of this package
+	Aggregated []*APIs
 }
 
 type APIGroup struct {
@@ -59,6 +64,11 @@ type APIGroup struct {
 	Aliases map[string]*Alias
 	Pkg     *types.Package
 	PkgPath string
+
+	// ValidationPatterns are the regular expressions used by the generated validation functions
+	ValidationPatterns []*ValidationPattern
+	// ValidationRules are the +validation:rule expressions used by the generated validation functions
+	ValidationRules []*ValidationRule
 }
 
 type Struct struct {
@@ -72,6 +82,20 @@ type Struct struct {
 	GenUnversioned bool
 	// Fields is the list of fields appearing in the struct
 	Fields []*Field
+
+	// Type is the versioned type the struct is generated from
+	Type *types.Type
+	// HasValidation indicates that a validation function is generated for the struct
+	HasValidation bool
+	// Validations are the statements of the validation function generated from the
+	// "+validation:" comment tags on the fields
+	Validations []string
+	// HasUpdateValidation indicates that an update validation function is generated for the struct
+	HasUpdateValidation bool
+	// UpdateValidations are the statements of the update validation function generated from the
+	// "+immutable" comment tags on the fields and the "+validation:rule" comment tags referring to
+	// oldSelf
+	UpdateValidations []string
 }
 
 type Alias struct {
@@ -100,6 +124,12 @@ type APIVersion struct {
 	Resources map[string]*APIResource
 	// Pkg is the Package object from code-gen
 	Pkg *types.Package
+	// Defaults are the structs with "+default=" comment tags on their fields
+	Defaults []*StructDefaults
+	// DefaultsUseJSON indicates that some defaults are decoded from json
+	DefaultsUseJSON bool
+	// ValidationRules are the structs with "+validation:rule" comment tags on them or their fields
+	ValidationRules []*StructValidationRules
 }
 
 type APIResource struct {
@@ -119,6 +149,8 @@ type APIResource struct {
 	// This field is optional. The standard REST implementation will be used
 	// by default.
 	REST string
+	// RESTContext indicates that the constructor of REST takes the *builders.RESTContext
+	RESTContext bool
 	// Subresources is a map of subresources keyed by name
 	Subresources map[string]*APISubresource
 	// Type is the Type object from code-gen
@@ -129,6 +161,52 @@ type APIResource struct {
 	StatusStrategy string
 	// NonNamespaced indicates that the resource kind is non namespaced
 	NonNamespaced bool
+	// StorageBackend is the name of the registered storage backend persisting the resource
+	// This field is optional. The default storage will be used by default.
+	StorageBackend string
+	// PrintColumns are the columns printed by kubectl get, parsed from the "+printcolumn" comments
+	PrintColumns []*PrintColumn
+	// Scale locates the replicas served by the scale subresource, parsed from the
+	// "+subresource:scale" comment. This field is optional.
+	Scale *ScaleSubresource
+	// SelectableFields are the fields supported by field selectors, parsed from the
+	// "+selectablefield" comments
+	SelectableFields []*SelectableField
+	// GracefulDeletion deletes the resource gracefully, parsed from the "+gracefuldeletion"
+	// comment. This field is optional.
+	GracefulDeletion *GracefulDeletion
+}
+
+// GracefulDeletion contains the tags present in a "+gracefuldeletion" comment
+type GracefulDeletion struct {
+	// GracePeriodSeconds is the grace period of the deletions that don't set one
+	GracePeriodSeconds int64
+}
+
+// ScaleSubresource contains the tags present in a "+subresource:scale" comment
+type ScaleSubresource struct {
+	// SpecReplicasPath is the path of the desired replicas - e.g. .spec.replicas
+	SpecReplicasPath string
+	// StatusReplicasPath is the path of the observed replicas - e.g. .status.replicas
+	StatusReplicasPath string
+	// LabelSelectorPath is the optional path of the label selector - e.g. .status.selector
+	LabelSelectorPath string
+}
+
+// PrintColumn is a column printed by kubectl get
+type PrintColumn struct {
+	// Name is the column header - e.g. Replicas
+	Name string
+	// Type is the OpenAPI type of the column - e.g. integer
+	Type string
+	// Format is the optional OpenAPI format of the column - e.g. name
+	Format string
+	// Description is the optional description of the column
+	Description string
+	// Priority is the column priority, columns with a priority above 0 are only printed in wide output
+	Priority int32
+	// JSONPath is the path of the value in the versioned object - e.g. .spec.replicas
+	JSONPath string
 }
 
 type APISubresource struct {
@@ -146,6 +224,8 @@ type APISubresource struct {
 	Request string
 	// REST is the rest.Storage implementation used to handle requests
 	REST string
+	// RESTContext indicates that the constructor of REST takes the *builders.RESTContext
+	RESTContext bool
 	// Path is the subresource path - e.g. scale
 	Path string
 
@@ -162,14 +242,20 @@ type APISubresource struct {
 type APIsBuilder struct {
 	context         *generator.Context
 	arguments       *args.GeneratorArgs
-	Domain          string
 	VersionedPkgs   sets.String
 	UnversionedPkgs sets.String
-	APIsPkg         string
-	APIsPkgRaw      *types.Package
-	GroupNames      sets.String
-
+	// APIsPkgs are the apis root packages containing the group packages
+	APIsPkgs sets.String
+	// APIsPkg is the apis package aggregating the groups of every apis package
+	APIsPkg string
+	// Domains are the domains of the apis packages parsed from their "+domain=" comment tag
+	Domains    map[string]string
+	GroupNames sets.String
+
+	// APIs are the groups of the aggregating apis package, the other apis packages are Aggregated
 	APIs *APIs
+	// Roots are the groups of every apis package by package path
+	Roots map[string]*APIs
 
 	ByGroupKindVersion    map[string]map[string]map[string]*APIResource
 	ByGroupVersionKind    map[string]map[string]map[string]*APIResource
@@ -182,9 +268,22 @@ func NewAPIsBuilder(context *generator.Context, arguments *args.GeneratorArgs) *
 		context:   context,
 		arguments: arguments,
 	}
-	b.ParsePackages()
+	apisPackage := ""
+	if customArgs, ok := arguments.CustomArgs.(*CustomArgs); ok {
+		apisPackage = customArgs.APIsPackage
+	}
+	diagnostics.universe = context.Universe
+	b.ParsePackages(apisPackage)
 	b.ParseDomain()
+	if len(diagnostics.list) > 0 {
+		// The apis packages are needed to parse the resources
+		return b
+	}
 	b.ParseGroupNames()
+	if len(diagnostics.list) > 0 {
+		// The resources of a group found in multiple apis packages would be merged
+		return b
+	}
 	b.ParseIndex()
 	b.ParseAPIs()
 
@@ -192,17 +291,27 @@ func NewAPIsBuilder(context *generator.Context, arguments *args.GeneratorArgs) *
 }
 
 func (b *APIsBuilder) ParseAPIs() {
-	apis := &APIs{
-		Domain:  b.Domain,
-		Package: b.APIsPkg,
-		Groups:  map[string]*APIGroup{},
+	b.Roots = map[string]*APIs{}
+	for _, pkg := range b.APIsPkgs.List() {
+		b.Roots[pkg] = &APIs{
+			Domain:  b.Domains[pkg],
+			Package: pkg,
+			Pkg:     b.context.Universe[pkg],
+			Groups:  map[string]*APIGroup{},
+		}
 	}
 
 	for group, versionMap := range b.ByGroupVersionKind {
+		domain, apis := "", (*APIs)(nil)
+		for _, kindMap := range versionMap {
+			for _, resource := range kindMap {
+				domain, apis = resource.Domain, b.Roots[apisPkg(resource.Type)]
+			}
+		}
 		apiGroup := &APIGroup{
 			Group:                group,
 			GroupTitle:           strings.Title(group),
-			Domain:               b.Domain,
+			Domain:               domain,
 			Versions:             map[string]*APIVersion{},
 			UnversionedResources: map[string]*APIResource{},
 			Aliases:              map[string]*Alias{},
@@ -210,25 +319,31 @@ func (b *APIsBuilder) ParseAPIs() {
 
 		for version, kindMap := range versionMap {
 			apiVersion := &APIVersion{
-				Domain:    b.Domain,
+				Domain:    domain,
 				Group:     group,
 				Version:   version,
 				Resources: map[string]*APIResource{},
 			}
 			for kind, resource := range kindMap {
 				apiResource := &APIResource{
-					Domain:         resource.Domain,
-					Version:        resource.Version,
-					Group:          resource.Group,
-					Resource:       resource.Resource,
-					Type:           resource.Type,
-					REST:           resource.REST,
-					Kind:           resource.Kind,
-					Subresources:   resource.Subresources,
-					StatusStrategy: resource.StatusStrategy,
-					Strategy:       resource.Strategy,
-					NonNamespaced:  resource.NonNamespaced,
-					ShortName:      resource.ShortName,
+					Domain:           resource.Domain,
+					Version:          resource.Version,
+					Group:            resource.Group,
+					Resource:         resource.Resource,
+					Type:             resource.Type,
+					REST:             resource.REST,
+					RESTContext:      resource.RESTContext,
+					Kind:             resource.Kind,
+					Subresources:     resource.Subresources,
+					StatusStrategy:   resource.StatusStrategy,
+					Strategy:         resource.Strategy,
+					NonNamespaced:    resource.NonNamespaced,
+					ShortName:        resource.ShortName,
+					StorageBackend:   resource.StorageBackend,
+					PrintColumns:     resource.PrintColumns,
+					Scale:            resource.Scale,
+					SelectableFields: resource.SelectableFields,
+					GracefulDeletion: resource.GracefulDeletion,
 				}
 				apiVersion.Resources[kind] = apiResource
 				// Set the package for the api version
@@ -240,13 +355,36 @@ func (b *APIsBuilder) ParseAPIs() {
 				apiGroup.UnversionedResources[kind] = apiResource
 			}
 
+			apiVersion.ParseDefaults()
+			apiVersion.ParseValidationRules()
 			apiGroup.Versions[version] = apiVersion
 		}
 		b.ParseStructsAndAliases(apiGroup)
+		apiGroup.ParseValidations()
 		apis.Groups[group] = apiGroup
 	}
-	apis.Pkg = b.context.Universe[b.APIsPkg]
-	b.APIs = apis
+
+	// The aggregating package imports the other apis packages by the name of their project
+	b.APIs = b.Roots[b.APIsPkg]
+	aliases := sets.NewString()
+	for _, pkg := range b.APIsPkgs.List() {
+		if pkg == b.APIsPkg {
+			continue
+		}
+		apis := b.Roots[pkg]
+		alias := strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, strings.ToLower(filepath.Base(filepath.Dir(filepath.Dir(pkg))))) + "apis"
+		apis.Alias = alias
+		for i := 2; aliases.Has(apis.Alias); i++ {
+			apis.Alias = fmt.Sprintf("%s%d", alias, i)
+		}
+		aliases.Insert(apis.Alias)
+		b.APIs.Aggregated = append(b.APIs.Aggregated, apis)
+	}
 }
 
 // ParseIndex indexes all types with the comment "// +resource=RESOURCE" by GroupVersionKind and
@@ -281,13 +419,48 @@ func (b *APIsBuilder) ParseIndex() {
 		r.Group = GetGroup(c)
 		r.Version = GetVersion(c, r.Group)
 		r.Kind = GetKind(c, r.Group)
-		r.Domain = b.Domain
+		r.Domain = b.Domains[apisPkg(c)]
 
-		rt := ParseResourceTag(b.GetResourceTag(c))
+		rt := ParseResourceTag(c, b.GetResourceTag(c))
 
 		r.Resource = rt.Resource
 		r.REST = rt.REST
+		if len(r.REST) > 0 {
+			r.RESTContext = b.TakesRESTContext(c, r.REST)
+		}
 		r.ShortName = rt.ShortName
+		r.StorageBackend = rt.Storage
+
+		for _, tag := range b.GetPrintColumnTags(c) {
+			r.PrintColumns = append(r.PrintColumns, ParsePrintColumnTag(c, tag))
+		}
+
+		indexed := 0
+		for _, tag := range b.GetSelectableFieldTags(c) {
+			f := ParseSelectableFieldTag(c, tag)
+			if f.Indexed {
+				indexed++
+			}
+			r.SelectableFields = append(r.SelectableFields, f)
+		}
+		if indexed > 1 {
+			typeError(c, "+selectablefield: the watch cache indexes a single field per resource, "+
+				"%d fields have index=true", indexed)
+		}
+
+		if tag, found := b.GetScaleSubresourceTag(c); found {
+			if len(r.REST) > 0 {
+				typeError(c, "+subresource:scale requires the standard storage but the resource uses rest=%s", r.REST)
+			}
+			r.Scale = ParseScaleSubresourceTag(c, tag)
+		}
+
+		if tag, found := b.GetGracefulDeletionTag(c); found {
+			if len(r.REST) > 0 {
+				typeError(c, "+gracefuldeletion requires the standard storage but the resource uses rest=%s", r.REST)
+			}
+			r.GracefulDeletion = ParseGracefulDeletionTag(c, tag)
+		}
 
 		r.Strategy = rt.Strategy
 
@@ -341,18 +514,21 @@ func (b *APIsBuilder) GetSubresources(c *APIResource) map[string]*APISubresource
 			Request:  tags.RequestKind,
 			Path:     tags.Path,
 			REST:     tags.REST,
-			Domain:   b.Domain,
+			Domain:   c.Domain,
 			Version:  c.Version,
 			Resource: c.Resource,
 			Group:    c.Group,
 		}
+		if len(sr.REST) > 0 {
+			sr.RESTContext = b.TakesRESTContext(c.Type, sr.REST)
+		}
 		if !b.IsInPackage(tags) {
 			// Out of package Request types require an import and are prefixed with the
 			// package name - e.g. v1.Scale
 			sr.Request, sr.ImportPackage = b.GetNameAndImport(tags)
 		}
 		if v, found := r[sr.Path]; found {
-			klog.Fatalf("Multiple subresources registered for path %s: %v %v",
+			typeError(c.Type, "Multiple subresources registered for path %s: %v %v",
 				sr.Path, v, subresource)
 		}
 		r[sr.Path] = sr
@@ -360,6 +536,25 @@ func (b *APIsBuilder) GetSubresources(c *APIResource) map[string]*APISubresource
 	return r
 }
 
+// TakesRESTContext returns true if the New{{REST}} constructor in the group package of the
+// resource type takes the *builders.RESTContext instead of the generic.RESTOptionsGetter
+func (b *APIsBuilder) TakesRESTContext(c *types.Type, rest string) bool {
+	pkg := b.context.Universe[filepath.Dir(c.Name.Package)]
+	if pkg == nil {
+		return false
+	}
+	fn, found := pkg.Functions["New"+rest]
+	if !found || fn.Underlying == nil || fn.Underlying.Signature == nil {
+		return false
+	}
+	params := fn.Underlying.Signature.Parameters
+	if len(params) != 1 || params[0].Kind != types.Pointer {
+		return false
+	}
+	param := params[0].Elem
+	return param.Name.Name == "RESTContext" && strings.HasSuffix(param.Name.Package, "/pkg/builders")
+}
+
 // Returns true if the subresource Request type is in the same package as the resource type
 func (b *APIsBuilder) IsInPackage(tags SubresourceTags) bool {
 	return !strings.Contains(tags.RequestKind, ".")
@@ -384,17 +579,22 @@ type ResourceTags struct {
 	REST      string
 	Strategy  string
 	ShortName string
+	Storage   string
 }
 
-// ParseResourceTag parses the tags in a "+resource=" comment into a ResourceTags struct
-func ParseResourceTag(tag string) ResourceTags {
+// ParseResourceTag parses the tags in a "+resource=" comment on the type c into a ResourceTags struct
+func ParseResourceTag(c *types.Type, tag string) ResourceTags {
 	result := ResourceTags{}
+	if len(tag) == 0 {
+		return result
+	}
 	for _, elem := range strings.Split(tag, ",") {
 		kv := strings.Split(elem, "=")
 		if len(kv) != 2 {
-			klog.Fatalf("// +resource: tags must be key value pairs.  Expected "+
+			typeError(c, "+resource: tags must be key value pairs.  Expected "+
 				"keys [path=<subresourcepath>] "+
 				"Got string: [%s]", tag)
+			continue
 		}
 		value := kv[1]
 		switch kv[0] {
@@ -406,6 +606,8 @@ func ParseResourceTag(tag string) ResourceTags {
 			result.Strategy = value
 		case "shortname":
 			result.ShortName = value
+		case "storage":
+			result.Storage = value
 		}
 	}
 	return result
@@ -425,9 +627,10 @@ func ParseSubresourceTag(c *APIResource, tag string) SubresourceTags {
 	for _, elem := range strings.Split(tag, ",") {
 		kv := strings.Split(elem, "=")
 		if len(kv) != 2 {
-			klog.Fatalf("// +subresource: tags must be key value pairs.  Expected "+
+			typeError(c.Type, "+subresource: tags must be key value pairs.  Expected "+
 				"keys [request=<requestType>,rest=<restImplType>,path=<subresourcepath>] "+
 				"Got string: [%s]", tag)
+			continue
 		}
 		value := kv[1]
 		switch kv[0] {
@@ -445,6 +648,127 @@ func ParseSubresourceTag(c *APIResource, tag string) SubresourceTags {
 	return result
 }
 
+// ParsePrintColumnTag parses the tags in a "+printcolumn:" comment into a PrintColumn struct.
+// Values may be quoted to contain commas - e.g. description="Desired replicas, not observed".
+func ParsePrintColumnTag(c *types.Type, tag string) *PrintColumn {
+	result := &PrintColumn{}
+	for _, elem := range splitQuoted(tag) {
+		kv := strings.SplitN(elem, "=", 2)
+		if len(kv) != 2 {
+			typeError(c, "+printcolumn: tags must be key value pairs.  Expected "+
+				"keys [name=<name>,type=<type>,JSONPath=<path>] "+
+				"Got string: [%s]", tag)
+			continue
+		}
+		value := strings.Trim(kv[1], `"`)
+		switch kv[0] {
+		case "name":
+			result.Name = value
+		case "type":
+			result.Type = value
+		case "format":
+			result.Format = value
+		case "description":
+			result.Description = value
+		case "priority":
+			priority, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				typeError(c, "+printcolumn: priority must be an integer. Got string: [%s]", tag)
+			}
+			result.Priority = int32(priority)
+		case "JSONPath":
+			result.JSONPath = value
+		}
+	}
+	if len(result.Name) == 0 || len(result.JSONPath) == 0 {
+		typeError(c, "+printcolumn: name and JSONPath are required. Got string: [%s]", tag)
+	}
+	switch result.Type {
+	case "integer", "number", "string", "boolean", "date":
+	default:
+		typeError(c, "+printcolumn: type must be one of integer, number, string, boolean or date. "+
+			"Got string: [%s]", tag)
+	}
+	return result
+}
+
+// splitQuoted splits the comma separated elements of a tag, ignoring the commas between quotes
+func splitQuoted(tag string) []string {
+	elems := []string{}
+	quoted := false
+	start := 0
+	for i, r := range tag {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			elems = append(elems, tag[start:i])
+			start = i + 1
+		}
+	}
+	return append(elems, tag[start:])
+}
+
+// ParseScaleSubresourceTag parses the tags in a "+subresource:scale" comment into a ScaleSubresource struct
+func ParseScaleSubresourceTag(c *types.Type, tag string) *ScaleSubresource {
+	result := &ScaleSubresource{}
+	for _, elem := range strings.Split(tag, ",")[1:] {
+		kv := strings.Split(elem, "=")
+		if len(kv) != 2 {
+			typeError(c, "+subresource:scale tags must be key value pairs.  Expected "+
+				"keys [specReplicasPath=<path>,statusReplicasPath=<path>,labelSelectorPath=<path>] "+
+				"Got string: [%s]", tag)
+			continue
+		}
+		value := kv[1]
+		switch kv[0] {
+		case "specReplicasPath":
+			result.SpecReplicasPath = value
+		case "statusReplicasPath":
+			result.StatusReplicasPath = value
+		case "labelSelectorPath":
+			result.LabelSelectorPath = value
+		}
+	}
+	if len(result.SpecReplicasPath) == 0 || len(result.StatusReplicasPath) == 0 {
+		typeError(c, "+subresource:scale requires specReplicasPath and statusReplicasPath. "+
+			"Got string: [%s]", tag)
+	}
+	for _, path := range []string{result.SpecReplicasPath, result.StatusReplicasPath, result.LabelSelectorPath} {
+		if len(path) > 0 && !strings.HasPrefix(path, ".") {
+			typeError(c, "+subresource:scale paths must start with '.'. Got string: [%s]", tag)
+			break
+		}
+	}
+	return result
+}
+
+// ParseGracefulDeletionTag parses the tags in a "+gracefuldeletion" comment into a GracefulDeletion
+// struct. The grace period defaults to 30 seconds.
+func ParseGracefulDeletionTag(c *types.Type, tag string) *GracefulDeletion {
+	result := &GracefulDeletion{GracePeriodSeconds: 30}
+	if len(tag) == 0 {
+		return result
+	}
+	for _, elem := range strings.Split(tag, ",") {
+		kv := strings.Split(elem, "=")
+		if len(kv) != 2 || kv[0] != "gracePeriodSeconds" {
+			typeError(c, "+gracefuldeletion tags must be key value pairs.  Expected "+
+				"keys [gracePeriodSeconds=<seconds>] "+
+				"Got string: [%s]", tag)
+			continue
+		}
+		seconds, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil || seconds < 0 {
+			typeError(c, "+gracefuldeletion gracePeriodSeconds must be a non-negative integer. "+
+				"Got string: [%s]", tag)
+			continue
+		}
+		result.GracePeriodSeconds = seconds
+	}
+	return result
+}
+
 // GetResourceTag returns the value of the "+resource=" comment tag
 func (b *APIsBuilder) GetResourceTag(c *types.Type) string {
 	comments := Comments(c.CommentLines)
@@ -456,7 +780,8 @@ func (b *APIsBuilder) GetResourceTag(c *types.Type) string {
 	if len(kbResource) != 0 {
 		return kbResource
 	}
-	panic(errors.Errorf("Must specify +resource or kubebuilder:resource comment for type %v", c.Name))
+	typeError(c, "Must specify +resource:path=<resource> or +kubebuilder:resource:path=<resource>")
+	return ""
 }
 
 func (b *APIsBuilder) GenClient(c *types.Type) bool {
@@ -484,28 +809,85 @@ func (b *APIsBuilder) GetControllerTag(c *types.Type) string {
 	panic(errors.Errorf("Must specify +controller or +kubebuilder:controller comment for type %v", c.Name))
 }
 
+// GetPrintColumnTags returns the values of the "+printcolumn:" and "+kubebuilder:printcolumn:"
+// comment tags
+func (b *APIsBuilder) GetPrintColumnTags(c *types.Type) []string {
+	comments := Comments(c.CommentLines)
+	return append(comments.GetTags("printcolumn", ":"), comments.GetTags("kubebuilder:printcolumn", ":")...)
+}
+
+// GetSelectableFieldTags returns the values of the "+selectablefield:" comment tags
+func (b *APIsBuilder) GetSelectableFieldTags(c *types.Type) []string {
+	comments := Comments(c.CommentLines)
+	return comments.GetTags("selectablefield", ":")
+}
+
+// GetGracefulDeletionTag returns the value of the "+gracefuldeletion" comment tag
+func (b *APIsBuilder) GetGracefulDeletionTag(c *types.Type) (string, bool) {
+	comments := Comments(c.CommentLines)
+	if !comments.HasTag("gracefuldeletion") {
+		return "", false
+	}
+	return comments.GetTag("gracefuldeletion", ":"), true
+}
+
+// GetSubresourceTags returns the values of the "+subresource:" comment tags except the
+// "+subresource:scale" tag
 func (b *APIsBuilder) GetSubresourceTags(c *types.Type) []string {
 	comments := Comments(c.CommentLines)
-	return comments.GetTags("subresource", ":")
+	tags := []string{}
+	for _, tag := range comments.GetTags("subresource", ":") {
+		if !isScaleSubresourceTag(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// GetScaleSubresourceTag returns the value of the "+subresource:scale" comment tag
+func (b *APIsBuilder) GetScaleSubresourceTag(c *types.Type) (string, bool) {
+	comments := Comments(c.CommentLines)
+	for _, tag := range comments.GetTags("subresource", ":") {
+		if isScaleSubresourceTag(tag) {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+func isScaleSubresourceTag(tag string) bool {
+	return tag == "scale" || strings.HasPrefix(tag, "scale,")
 }
 
 // ParseGroupNames initializes b.GroupNames with the set of all groups
 func (b *APIsBuilder) ParseGroupNames() {
 	b.GroupNames = sets.String{}
-	for p := range b.UnversionedPkgs {
+	// The groups are indexed by their name, which must be unique across the apis packages
+	groupPkgs := map[string]string{}
+	for _, p := range b.UnversionedPkgs.List() {
 		pkg := b.context.Universe[p]
 		if pkg == nil {
 			// If the input had no Go files, for example.
 			continue
 		}
-		b.GroupNames.Insert(filepath.Base(p))
+		name := filepath.Base(p)
+		if other, found := groupPkgs[name]; found {
+			packageError(p, "Found group %s in multiple apis packages: %s and %s",
+				name, filepath.Dir(other), filepath.Dir(p))
+			continue
+		}
+		groupPkgs[name] = p
+		b.GroupNames.Insert(name)
 	}
 }
 
-// ParsePackages parses out the sets of Versioned, Unversioned packages and identifies the root Apis package.
-func (b *APIsBuilder) ParsePackages() {
+// ParsePackages parses out the sets of Versioned, Unversioned packages and identifies the root Apis
+// packages. The groups of every apis package are aggregated by the apis package apisPackage, which
+// may be left empty when there is a single apis package.
+func (b *APIsBuilder) ParsePackages(apisPackage string) {
 	b.VersionedPkgs = sets.NewString()
 	b.UnversionedPkgs = sets.NewString()
+	b.APIsPkgs = sets.NewString()
 	for _, o := range b.context.Order {
 		if IsAPIResource(o) {
 			versioned := o.Name.Package
@@ -514,32 +896,56 @@ func (b *APIsBuilder) ParsePackages() {
 			unversioned := filepath.Dir(versioned)
 			b.UnversionedPkgs.Insert(unversioned)
 
-			if apis := filepath.Dir(unversioned); apis != b.APIsPkg && len(b.APIsPkg) > 0 {
-				panic(errors.Errorf(
-					"Found multiple apis directory paths: %v and %v.  "+
-						"Do you have a +resource tag on a resource that is not in a version "+
-						"directory?", b.APIsPkg, apis))
-			} else {
-				b.APIsPkg = apis
-			}
+			b.APIsPkgs.Insert(apisPkg(o))
+		}
+	}
+
+	switch {
+	case len(apisPackage) > 0:
+		if !b.APIsPkgs.Has(apisPackage) {
+			packageError(apisPackage,
+				"The apis package %v has no resources, found resources in apis packages %v",
+				apisPackage, b.APIsPkgs.List())
 		}
+		b.APIsPkg = apisPackage
+	case b.APIsPkgs.Len() > 1:
+		packageError("",
+			"Found multiple apis directory paths: %v.  "+
+				"Set --apis-package to the apis package aggregating them, or do you have a "+
+				"+resource tag on a resource that is not in a version directory?", b.APIsPkgs.List())
+	case b.APIsPkgs.Len() == 1:
+		b.APIsPkg = b.APIsPkgs.List()[0]
 	}
 }
 
-// ParseDomain parses the domain from the apis/doc.go file comment "// +domain=YOUR_DOMAIN".
+// ParseDomain parses the domain of every apis package from its doc.go file comment
+// "// +domain=YOUR_DOMAIN".
 func (b *APIsBuilder) ParseDomain() {
-	pkg := b.context.Universe[b.APIsPkg]
-	if pkg == nil {
-		// If the input had no Go files, for example.
-		panic(errors.Errorf("Missing apis package."))
+	if b.APIsPkgs.Len() == 0 {
+		packageError("", "Missing apis package.")
+		return
 	}
-	comments := Comments(pkg.Comments)
-	b.Domain = comments.GetTag("domain", "=")
-	if len(b.Domain) == 0 {
-		panic("Could not find string matching // +domain=.+ in apis/doc.go")
+	b.Domains = map[string]string{}
+	for _, p := range b.APIsPkgs.List() {
+		pkg := b.context.Universe[p]
+		if pkg == nil {
+			// If the input had no Go files, for example.
+			packageError(p, "Missing apis package %s.", p)
+			continue
+		}
+		comments := Comments(pkg.Comments)
+		b.Domains[p] = comments.GetTag("domain", "=")
+		if len(b.Domains[p]) == 0 {
+			packageError(p, "Could not find string matching // +domain=.+ in %s/doc.go", p)
+		}
 	}
 }
 
+// apisPkg returns the apis root package of the resource type t
+func apisPkg(t *types.Type) string {
+	return filepath.Dir(filepath.Dir(t.Name.Package))
+}
+
 type GenUnversionedType struct {
 	Type     *types.Type
 	Resource *APIResource
@@ -607,6 +1013,7 @@ func (apigroup *APIGroup) DoType(t *types.Type) (*Struct, []*types.Type) {
 		Name:           t.Name.Name,
 		GenClient:      false,
 		GenUnversioned: true, // Generate unversioned structs by default
+		Type:           t,
 	}
 
 	for _, c := range t.CommentLines {
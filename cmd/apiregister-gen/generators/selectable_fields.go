@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/gengo/types"
+)
+
+// SelectableField is a field of a resource supported by field selectors
+type SelectableField struct {
+	// Label is the field selector label - e.g. spec.foo
+	Label string
+	// Value is the statement setting the field value in the fields.Set named set
+	Value string
+	// Indexed is true if the watch cache indexes the field
+	Indexed bool
+}
+
+// ParseSelectableFieldTag parses a "+selectablefield:JSONPath=" comment tag on the resource
+// type c into the statement reading the field from the unversioned object named obj.
+func ParseSelectableFieldTag(c *types.Type, tag string) *SelectableField {
+	path := ""
+	indexed := false
+	for _, elem := range strings.Split(tag, ",") {
+		kv := strings.SplitN(elem, "=", 2)
+		if len(kv) != 2 {
+			typeError(c, "+selectablefield: tags must be key value pairs.  Expected "+
+				"keys [JSONPath=<path>,index=<true|false>] "+
+				"Got string: [%s]", tag)
+			continue
+		}
+		switch kv[0] {
+		case "JSONPath":
+			path = strings.Trim(kv[1], `"`)
+		case "index":
+			b, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				typeError(c, "+selectablefield: index must be true or false. Got string: [%s]", tag)
+			}
+			indexed = b
+		}
+	}
+	if !strings.HasPrefix(path, ".") {
+		typeError(c, "+selectablefield: JSONPath=<path> is required. "+
+			"Got string: [%s]", tag)
+		return &SelectableField{}
+	}
+	label := strings.TrimPrefix(path, ".")
+	if label == "metadata.name" || label == "metadata.namespace" {
+		typeError(c, "+selectablefield: %s is always selectable", label)
+		return &SelectableField{Label: label}
+	}
+
+	// Follow the json field names to the Go fields, pointers are only read when they are set
+	value := "obj"
+	guards := []string{}
+	pointer := false
+	t := c
+	for _, name := range strings.Split(label, ".") {
+		for t.Kind == types.Alias {
+			t = t.Underlying
+		}
+		if t.Kind != types.Struct {
+			typeError(c, "+selectablefield: %s is not a field of a struct", label)
+			return &SelectableField{Label: label}
+		}
+		var member *types.Member
+		for i := range t.Members {
+			if jsonName(t.Members[i]) == name {
+				member = &t.Members[i]
+				break
+			}
+		}
+		if member == nil {
+			typeError(c, "+selectablefield: no field has the json name %s in %s", name, label)
+			return &SelectableField{Label: label}
+		}
+		value = value + "." + member.Name
+		t = member.Type
+		pointer = t.Kind == types.Pointer
+		if pointer {
+			guards = append(guards, value+" != nil")
+			t = t.Elem
+		}
+	}
+	if pointer {
+		value = "*" + value
+	}
+
+	underlying := t
+	for underlying.Kind == types.Alias {
+		underlying = underlying.Underlying
+	}
+	switch {
+	case underlying.Kind == types.Builtin && underlying.Name.Name == "string":
+		value = fmt.Sprintf("string(%s)", value)
+	case underlying.Kind == types.Builtin && (underlying.Name.Name == "bool" || numericTypes[underlying.Name.Name]):
+		value = fmt.Sprintf("fmt.Sprint(%s)", value)
+	default:
+		typeError(c, "+selectablefield: %s must be a string, boolean or numeric field", label)
+		return &SelectableField{Label: label}
+	}
+
+	result := &SelectableField{Label: label, Indexed: indexed}
+	if len(guards) == 0 {
+		result.Value = fmt.Sprintf("set[%q] = %s", label, value)
+		return result
+	}
+	// Unset fields are selectable as empty values
+	result.Value = fmt.Sprintf("set[%q] = \"\"\n\tif %s {\n\t\tset[%q] = %s\n\t}",
+		label, strings.Join(guards, " && "), label, value)
+	return result
+}
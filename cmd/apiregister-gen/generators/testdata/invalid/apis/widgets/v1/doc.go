@@ -0,0 +1,23 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +k8s:openapi-gen=true
+// +k8s:deepcopy-gen=package,register
+// +k8s:conversion-gen=sigs.k8s.io/apiserver-builder-alpha/cmd/apiregister-gen/generators/testdata/invalid/apis/widgets
+// +k8s:defaulter-gen=TypeMeta
+
+// +groupName=widgets.example.com
+package v1 // import "sigs.k8s.io/apiserver-builder-alpha/cmd/apiregister-gen/generators/testdata/invalid/apis/widgets/v1"
@@ -18,6 +18,7 @@ package generators
 
 import (
 	"io"
+	"strconv"
 	"text/template"
 
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -43,10 +44,18 @@ func (d *unversionedGenerator) Imports(c *generator.Context) []string {
 	imports := sets.NewString(
 		"fmt",
 		"context",
+		"reflect",
+		"regexp",
+		"apiequality \"k8s.io/apimachinery/pkg/api/equality\"",
+		"autoscalingv1 \"k8s.io/api/autoscaling/v1\"",
 		"sigs.k8s.io/apiserver-builder-alpha/pkg/builders",
+		"sigs.k8s.io/apiserver-builder-alpha/pkg/validators",
 		"k8s.io/apimachinery/pkg/apis/meta/internalversion",
+		"k8s.io/apimachinery/pkg/fields",
 		"k8s.io/apimachinery/pkg/runtime",
 		"k8s.io/apimachinery/pkg/runtime/schema",
+		"k8s.io/apimachinery/pkg/util/sets",
+		"k8s.io/apimachinery/pkg/util/validation/field",
 		"k8s.io/apiserver/pkg/registry/rest")
 
 	// Get imports for all fields
@@ -65,6 +74,7 @@ func (d *unversionedGenerator) Finalize(context *generator.Context, w io.Writer)
 	temp := template.
 		Must(template.New("unversioned-wiring-template").Funcs(map[string]interface{}{
 			"public": namer.IC,
+			"quote":  strconv.Quote,
 		}).Parse(UnversionedAPITemplate))
 
 	err := temp.Execute(w, d.apigroup)
@@ -78,19 +88,42 @@ var UnversionedAPITemplate = `
 var (
 	{{ range $api := .UnversionedResources -}}
 	{{ if $api.REST -}}
-		{{$api.Group|public}}{{$api.Kind}}Storage = builders.NewApiResourceWithStorage( // Resource status endpoint
+		{{$api.Group|public}}{{$api.Kind}}Storage = builders.{{ if $api.RESTContext }}NewApiResourceWithRESTContext{{ else }}NewApiResourceWithStorage{{ end }}( // Resource status endpoint
 			Internal{{ $api.Kind }},
 			func() runtime.Object { return &{{ $api.Kind }}{} },     // Register versioned resource
 			func() runtime.Object { return &{{ $api.Kind }}List{} }, // Register versioned resource list
 			New{{ $api.REST }},
-		)
+		){{ if $api.StorageBackend }}.WithStorageBackend("{{ $api.StorageBackend }}"){{ end }}
 	{{ else -}}
 		{{$api.Group|public}}{{$api.Kind}}Storage = builders.NewApiResource( // Resource status endpoint
 			Internal{{ $api.Kind }},
 			func() runtime.Object { return &{{ $api.Kind }}{} },     // Register versioned resource
 			func() runtime.Object { return &{{ $api.Kind }}List{} }, // Register versioned resource list
 			&{{ $api.Strategy }}{builders.StorageStrategySingleton},
-		)
+		){{ if $api.StorageBackend }}.WithStorageBackend("{{ $api.StorageBackend }}"){{ end }}{{ if $api.PrintColumns }}.WithTableConvertor({{ $api.Kind }}TableConvertor){{ end }}{{ if $api.GracefulDeletion }}.WithGracefulDeletion({{ $api.GracefulDeletion.GracePeriodSeconds }}){{ end }}
+	{{ end -}}
+	{{ end -}}
+	{{ range $api := .UnversionedResources -}}
+	{{ if $api.PrintColumns -}}
+	// {{ $api.Kind }}TableConvertor prints the +printcolumn columns of {{ $api.Kind }}
+	{{ $api.Kind }}TableConvertor = builders.NewTableConvertor(
+		{{ range $c := $api.PrintColumns -}}
+		builders.TableColumn{
+			Name: {{ quote $c.Name }},
+			Type: {{ quote $c.Type }},
+			{{ if $c.Format -}}
+			Format: {{ quote $c.Format }},
+			{{ end -}}
+			{{ if $c.Description -}}
+			Description: {{ quote $c.Description }},
+			{{ end -}}
+			{{ if $c.Priority -}}
+			Priority: {{ $c.Priority }},
+			{{ end -}}
+			JSONPath: {{ quote $c.JSONPath }},
+		},
+		{{ end -}}
+	)
 	{{ end -}}
 	{{ end -}}
 	{{ range $api := .UnversionedResources -}}
@@ -114,6 +147,13 @@ var (
 		func() runtime.Object { return &{{ $api.Kind }}{} },
 		func() runtime.Object { return &{{ $api.Kind }}List{} },
 	)
+	{{ if .Scale -}}
+	// The scale subresource serves autoscaling/v1 Scale objects registered in builders.Scheme
+	Internal{{ $api.Kind }}Scale = builders.NewInternalSubresource(
+		"{{ $api.Resource }}", "Scale", "scale",
+		func() runtime.Object { return &autoscalingv1.Scale{} },
+	)
+	{{ end -}}
 	{{ range $subresource := .Subresources -}}
 	Internal{{$subresource.Kind}}REST = builders.NewInternalSubresource(
 		"{{$subresource.Resource}}", "{{$subresource.Request}}", "{{$subresource.Path}}",
@@ -173,6 +213,73 @@ type {{ $s.Name }} struct {
 {{ end -}}
 {{ end -}}
 
+{{ range $p := .ValidationPatterns -}}
+var {{ $p.Name }} = regexp.MustCompile({{ $p.Pattern }})
+{{ end -}}
+{{ range $r := .ValidationRules -}}
+var {{ $r.Name }} = validators.MustCompileRule({{ $r.Rule }}, {{ $r.Message }})
+{{ end -}}
+
+{{ range $s := .Structs -}}
+{{ if $s.HasValidation -}}
+// validate{{ $s.Name }}Fields validates the fields of {{ $s.Name }} against their +validation comment tags
+func validate{{ $s.Name }}Fields(obj *{{ $s.Name }}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	{{ range $v := $s.Validations -}}
+	{{ $v }}
+	{{ end -}}
+	return errs
+}
+
+{{ if $s.GenClient -}}
+// ValidateFields validates {{ $s.Name }} against the +validation comment tags of its fields
+func (obj *{{ $s.Name }}) ValidateFields() field.ErrorList {
+	return validate{{ $s.Name }}Fields(obj, nil)
+}
+{{ end -}}
+{{ end -}}
+{{ if $s.HasUpdateValidation -}}
+// validate{{ $s.Name }}FieldUpdates validates the update of the fields of {{ $s.Name }} against their +immutable and oldSelf +validation:rule comment tags
+func validate{{ $s.Name }}FieldUpdates(obj, old *{{ $s.Name }}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	{{ range $v := $s.UpdateValidations -}}
+	{{ $v }}
+	{{ end -}}
+	return errs
+}
+
+{{ if $s.GenClient -}}
+// ValidateFieldUpdates validates the update of {{ $s.Name }} from old against the +immutable and oldSelf +validation:rule comment tags of its fields
+func (obj *{{ $s.Name }}) ValidateFieldUpdates(old runtime.Object) field.ErrorList {
+	return validate{{ $s.Name }}FieldUpdates(obj, old.(*{{ $s.Name }}), nil)
+}
+{{ end -}}
+{{ end -}}
+{{ end -}}
+
+{{ range $api := .UnversionedResources -}}
+{{ if $api.SelectableFields -}}
+// GetSelectableFields returns the fields of {{ $api.Kind }} declared with +selectablefield
+func (obj *{{ $api.Kind }}) GetSelectableFields() fields.Set {
+	set := fields.Set{}
+	{{ range $f := $api.SelectableFields -}}
+	{{ $f.Value }}
+	{{ end -}}
+	return set
+}
+
+{{ range $f := $api.SelectableFields -}}
+{{ if $f.Indexed -}}
+// GetIndexedFields returns the fields of {{ $api.Kind }} indexed by the watch cache
+func (obj *{{ $api.Kind }}) GetIndexedFields() []string {
+	return []string{ {{- quote $f.Label -}} }
+}
+
+{{ end -}}
+{{ end -}}
+{{ end -}}
+{{ end -}}
+
 {{ range $api := .UnversionedResources -}}
 //
 // {{.Kind}} Functions and Structs
@@ -260,9 +367,6 @@ type storage{{.Kind}} struct {
 }
 
 func (s *storage{{.Kind}}) List{{.Kind}}s(ctx context.Context, options *internalversion.ListOptions) (*{{.Kind}}List, error) {
-	if options != nil && options.FieldSelector != nil && !options.FieldSelector.Empty() {
-		return nil, fmt.Errorf("field selector not supported yet")
-	}
 	st := s.GetStandardStorage()
 	obj, err := st.List(ctx, options)
 	if err != nil {
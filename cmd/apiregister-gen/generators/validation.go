@@ -0,0 +1,411 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/gengo/types"
+)
+
+// validationMarkers are the supported "+validation:<marker>" field comment tags
+var validationMarkers = []string{
+	"Minimum", "Maximum", "Pattern", "MinLength", "MaxLength", "MinItems", "MaxItems", "Enum", "Required", "rule",
+}
+
+var numericTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+// ValidationPattern is a regular expression compiled once for a "+validation:Pattern=" marker
+type ValidationPattern struct {
+	// Name is the name of the package variable holding the compiled expression
+	Name string
+	// Pattern is the quoted regular expression
+	Pattern string
+}
+
+// fieldValidation is the generated validation of a single struct field
+type fieldValidation struct {
+	// checks are the statements validating the field value itself
+	checks []string
+	// nested is the name of the struct validated through its own validation function
+	nested string
+	// nestedCall is the statement calling the validation function of the nested struct
+	nestedCall string
+
+	// updateChecks are the statements validating the update of the field value itself
+	updateChecks []string
+	// nestedUpdateCall is the statement calling the update validation function of the nested
+	// struct, unset if the field is immutable as a whole
+	nestedUpdateCall string
+}
+
+// ParseValidations generates the validation statements of every unversioned struct from the
+// "+validation:" comment tags on the fields of the versioned types. A struct is validated if any
+// of its fields have markers, or if it contains a struct of the same group that is validated.
+func (apigroup *APIGroup) ParseValidations() {
+	byName := map[string]*Struct{}
+	for _, s := range apigroup.Structs {
+		if s.GenUnversioned && s.Type != nil {
+			byName[s.Name] = s
+		}
+	}
+
+	fields := map[string][]*fieldValidation{}
+	for _, s := range apigroup.Structs {
+		if _, found := byName[s.Name]; !found {
+			continue
+		}
+		for _, member := range s.Type.Members {
+			fields[s.Name] = append(fields[s.Name], apigroup.parseFieldValidation(s.Type, member))
+		}
+		// The rules on the struct itself are validated with its fields
+		checks, updateChecks := apigroup.parseValidationRules(s.Name, typeComments(s.Type), "obj", "old", "path")
+		fields[s.Name] = append(fields[s.Name], &fieldValidation{checks: checks, updateChecks: updateChecks})
+	}
+
+	validated := validatedStructs(fields,
+		func(fv *fieldValidation) bool { return len(fv.checks) > 0 },
+		func(fv *fieldValidation) string { return fv.nestedCall })
+	for _, s := range apigroup.Structs {
+		if !validated[s.Name] {
+			continue
+		}
+		s.HasValidation = true
+		for _, fv := range fields[s.Name] {
+			s.Validations = append(s.Validations, fv.checks...)
+			if len(fv.nested) > 0 && validated[fv.nested] {
+				s.Validations = append(s.Validations, fv.nestedCall)
+			}
+		}
+	}
+
+	updateValidated := validatedStructs(fields,
+		func(fv *fieldValidation) bool { return len(fv.updateChecks) > 0 },
+		func(fv *fieldValidation) string { return fv.nestedUpdateCall })
+	for _, s := range apigroup.Structs {
+		if !updateValidated[s.Name] {
+			continue
+		}
+		s.HasUpdateValidation = true
+		for _, fv := range fields[s.Name] {
+			s.UpdateValidations = append(s.UpdateValidations, fv.updateChecks...)
+			if len(fv.nestedUpdateCall) > 0 && updateValidated[fv.nested] {
+				s.UpdateValidations = append(s.UpdateValidations, fv.nestedUpdateCall)
+			}
+		}
+	}
+}
+
+// validatedStructs returns the structs that need a validation function because they validate any
+// of their fields directly or by calling the function of a nested struct, iterating until nested
+// structs stop adding validated structs
+func validatedStructs(fields map[string][]*fieldValidation,
+	direct func(*fieldValidation) bool, nestedCall func(*fieldValidation) string) map[string]bool {
+	validated := map[string]bool{}
+	for changed := true; changed; {
+		changed = false
+		for name, fvs := range fields {
+			if validated[name] {
+				continue
+			}
+			for _, fv := range fvs {
+				if direct(fv) || (len(nestedCall(fv)) > 0 && validated[fv.nested]) {
+					validated[name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return validated
+}
+
+// parseFieldValidation returns the validation statements for a field of the versioned type t
+func (apigroup *APIGroup) parseFieldValidation(t *types.Type, member types.Member) *fieldValidation {
+	result := &fieldValidation{}
+
+	value := "obj." + member.Name
+	path := fmt.Sprintf("path.Child(%q)", jsonName(member))
+	if member.Embedded {
+		// Embedded structs are inlined into the parent object
+		value = "obj." + member.Type.Name.Name
+		path = "path"
+	}
+	// old is the value of the field before an update
+	old := "old" + strings.TrimPrefix(value, "obj")
+
+	fieldType := member.Type
+	pointer := fieldType.Kind == types.Pointer
+	if pointer {
+		fieldType = fieldType.Elem
+	}
+	underlying := fieldType
+	for underlying.Kind == types.Alias {
+		underlying = underlying.Underlying
+	}
+
+	// Validate nested structs of the same group with their own validation function
+	switch {
+	case underlying.Kind == types.Struct && GetGroup(fieldType) == GetGroup(t):
+		result.nested = fieldType.Name.Name
+		if pointer {
+			result.nestedCall = fmt.Sprintf("if %s != nil {\n\t\terrs = append(errs, validate%sFields(%s, %s)...)\n\t}",
+				value, result.nested, value, path)
+			result.nestedUpdateCall = fmt.Sprintf("if %s != nil && %s != nil {\n\t\terrs = append(errs, validate%sFieldUpdates(%s, %s, %s)...)\n\t}",
+				value, old, result.nested, value, old, path)
+		} else {
+			result.nestedCall = fmt.Sprintf("errs = append(errs, validate%sFields(&%s, %s)...)",
+				result.nested, value, path)
+			result.nestedUpdateCall = fmt.Sprintf("errs = append(errs, validate%sFieldUpdates(&%s, &%s, %s)...)",
+				result.nested, value, old, path)
+		}
+	case !pointer && underlying.Kind == types.Slice &&
+		underlying.Elem.Kind == types.Struct && GetGroup(underlying.Elem) == GetGroup(t):
+		result.nested = underlying.Elem.Name.Name
+		result.nestedCall = fmt.Sprintf("for i := range %s {\n\t\terrs = append(errs, validate%sFields(&%s[i], %s.Index(i))...)\n\t}",
+			value, result.nested, value, path)
+		// The items are compared by index, the added items have nothing to compare to
+		result.nestedUpdateCall = fmt.Sprintf("for i := range %s {\n\t\tif i < len(%s) {\n\t\t\terrs = append(errs, validate%sFieldUpdates(&%s[i], &%s[i], %s.Index(i))...)\n\t\t}\n\t}",
+			value, old, result.nested, value, old, path)
+	}
+
+	comments := Comments(trimComments(member.CommentLines))
+	for _, c := range comments {
+		if !strings.HasPrefix(c, "+validation:") {
+			continue
+		}
+		marker := strings.SplitN(strings.TrimPrefix(c, "+validation:"), "=", 2)[0]
+		if !isValidationMarker(marker) {
+			fieldError(t, member.Name, "Unknown marker +validation:%s, expected one of %v",
+				marker, validationMarkers)
+		}
+	}
+
+	// deref is the expression reading the field value, checks on pointers only apply when set
+	deref := value
+	if pointer {
+		deref = "*" + value
+	}
+	checks := []string{}
+	fail := func(format string, args ...interface{}) {
+		fieldError(t, member.Name, "Invalid validation marker: %s", fmt.Sprintf(format, args...))
+	}
+	isNumeric := underlying.Kind == types.Builtin && numericTypes[underlying.Name.Name]
+	isString := underlying.Kind == types.Builtin && underlying.Name.Name == "string"
+	isList := underlying.Kind == types.Slice || underlying.Kind == types.Map
+
+	if v := comments.GetTag("validation:Minimum", "="); len(v) > 0 {
+		if !isNumeric {
+			fail("+validation:Minimum=%s requires a numeric value on a numeric field", v)
+		} else if err := parseNumber(underlying.Name.Name, v); err != nil {
+			fail("+validation:Minimum=%s is not a valid %s value: %v", v, underlying.Name.Name, err)
+		}
+		checks = append(checks, fmt.Sprintf(
+			"if %s < %s {\n\t\terrs = append(errs, field.Invalid(%s, %s, %q))\n\t}",
+			deref, v, path, deref, "should be greater than or equal to "+v))
+	}
+	if v := comments.GetTag("validation:Maximum", "="); len(v) > 0 {
+		if !isNumeric {
+			fail("+validation:Maximum=%s requires a numeric value on a numeric field", v)
+		} else if err := parseNumber(underlying.Name.Name, v); err != nil {
+			fail("+validation:Maximum=%s is not a valid %s value: %v", v, underlying.Name.Name, err)
+		}
+		checks = append(checks, fmt.Sprintf(
+			"if %s > %s {\n\t\terrs = append(errs, field.Invalid(%s, %s, %q))\n\t}",
+			deref, v, path, deref, "should be less than or equal to "+v))
+	}
+	if v := comments.GetTag("validation:Pattern", "="); len(v) > 0 {
+		if _, err := regexp.Compile(v); err != nil || !isString {
+			fail("+validation:Pattern=%s requires a valid regular expression on a string field", v)
+		}
+		pattern := &ValidationPattern{
+			Name:    fmt.Sprintf("%s%sPattern", strings.ToLower(t.Name.Name[:1])+t.Name.Name[1:], member.Name),
+			Pattern: strconv.Quote(v),
+		}
+		apigroup.ValidationPatterns = append(apigroup.ValidationPatterns, pattern)
+		checks = append(checks, fmt.Sprintf(
+			"if !%s.MatchString(string(%s)) {\n\t\terrs = append(errs, field.Invalid(%s, %s, %q))\n\t}",
+			pattern.Name, deref, path, deref, "should match '"+v+"'"))
+	}
+	for _, length := range []struct {
+		marker, op, message string
+		applies             bool
+	}{
+		{"MinLength", "<", "should be at least %s chars long", isString},
+		{"MaxLength", ">", "may not be longer than %s chars", isString},
+		{"MinItems", "<", "should have at least %s items", isList},
+		{"MaxItems", ">", "may not have more than %s items", isList},
+	} {
+		v := comments.GetTag("validation:"+length.marker, "=")
+		if len(v) == 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(v); err != nil || !length.applies {
+			fail("+validation:%s=%s requires an integer value on a supported field type", length.marker, v)
+		}
+		checks = append(checks, fmt.Sprintf(
+			"if len(%s) %s %s {\n\t\terrs = append(errs, field.Invalid(%s, %s, %q))\n\t}",
+			deref, length.op, v, path, deref, fmt.Sprintf(length.message, v)))
+	}
+	if v := comments.GetTag("validation:Enum", "="); len(v) > 0 {
+		if !isString {
+			fail("+validation:Enum=%s is only supported on string fields", v)
+		}
+		values := []string{}
+		for _, e := range strings.Split(v, ";") {
+			values = append(values, strconv.Quote(e))
+		}
+		checks = append(checks, fmt.Sprintf(
+			"if supported := []string{%s}; !sets.NewString(supported...).Has(string(%s)) {\n\t\terrs = append(errs, field.NotSupported(%s, %s, supported))\n\t}",
+			strings.Join(values, ", "), deref, path, deref))
+	}
+
+	ruleChecks, ruleUpdateChecks := apigroup.parseValidationRules(
+		t.Name.Name+"."+member.Name, comments, value, old, path)
+	checks = append(checks, ruleChecks...)
+	for _, c := range ruleUpdateChecks {
+		if pointer {
+			// The rules comparing to oldSelf apply when the field is set before and after the update
+			c = fmt.Sprintf("if %s != nil && %s != nil {\n\t\t%s\n\t}", value, old, c)
+		}
+		result.updateChecks = append(result.updateChecks, c)
+	}
+
+	// Value checks only apply to fields that are set, unset fields are reported by Required
+	set := ""
+	switch {
+	case pointer:
+		set = fmt.Sprintf("%s != nil", value)
+	case isString || isList:
+		set = fmt.Sprintf("len(%s) != 0", value)
+	}
+	if len(set) > 0 && len(checks) > 0 {
+		for i := range checks {
+			checks[i] = strings.Replace(checks[i], "\n", "\n\t", -1)
+		}
+		checks = []string{fmt.Sprintf("if %s {\n\t\t%s\n\t}", set, strings.Join(checks, "\n\t\t"))}
+	}
+
+	if comments.HasTag("validation:Required") {
+		switch {
+		case pointer:
+			checks = append([]string{fmt.Sprintf(
+				"if %s == nil {\n\t\terrs = append(errs, field.Required(%s, \"\"))\n\t}", value, path)}, checks...)
+		case isString || isList:
+			checks = append([]string{fmt.Sprintf(
+				"if len(%s) == 0 {\n\t\terrs = append(errs, field.Required(%s, \"\"))\n\t}", value, path)}, checks...)
+		default:
+			fail("+validation:Required is only supported on pointer, string, slice and map fields")
+		}
+	}
+
+	result.checks = checks
+
+	// Immutable fields can't change once created, once-set fields can't change once set
+	for _, c := range comments {
+		if c != "+immutable" && !strings.HasPrefix(c, "+immutable:") {
+			continue
+		}
+		switch c {
+		case "+immutable":
+			result.updateChecks = append(result.updateChecks, fmt.Sprintf(
+				"if !apiequality.Semantic.DeepEqual(%s, %s) {\n\t\terrs = append(errs, field.Forbidden(%s, \"field is immutable\"))\n\t}",
+				value, old, path))
+			// The nested fields can't change either
+			result.nestedUpdateCall = ""
+		case "+immutable:once-set":
+			set := ""
+			switch {
+			case pointer:
+				set = fmt.Sprintf("%s != nil", old)
+			case isString || isList:
+				set = fmt.Sprintf("len(%s) != 0", old)
+			case isNumeric:
+				set = fmt.Sprintf("%s != 0", old)
+			default:
+				set = fmt.Sprintf("!reflect.ValueOf(%s).IsZero()", old)
+			}
+			result.updateChecks = append(result.updateChecks, fmt.Sprintf(
+				"if %s && !apiequality.Semantic.DeepEqual(%s, %s) {\n\t\terrs = append(errs, field.Forbidden(%s, \"field is immutable once set\"))\n\t}",
+				set, value, old, path))
+		default:
+			fieldError(t, member.Name, "Unknown marker %s, expected +immutable or +immutable:once-set", c)
+		}
+	}
+	return result
+}
+
+// parseNumber checks that v is a literal of the numeric builtin type name, the generated
+// comparisons don't compile with fractions on integer fields or out of range values
+func parseNumber(name, v string) error {
+	var err error
+	switch bits := strings.TrimLeft(name, "uintfloa"); {
+	case strings.HasPrefix(name, "float"):
+		_, err = strconv.ParseFloat(v, 64)
+	case strings.HasPrefix(name, "uint"):
+		_, err = strconv.ParseUint(v, 10, bitSize(bits))
+	default:
+		_, err = strconv.ParseInt(v, 10, bitSize(bits))
+	}
+	if numErr, ok := err.(*strconv.NumError); ok {
+		return numErr.Err
+	}
+	return err
+}
+
+// bitSize returns the size of a numeric type from its suffix, e.g. 32 for int32, 0 for int
+func bitSize(suffix string) int {
+	size, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+func isValidationMarker(marker string) bool {
+	for _, m := range validationMarkers {
+		if m == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonName returns the name of the field in its json serialization
+func jsonName(member types.Member) string {
+	name := strings.Split(reflect.StructTag(member.Tags).Get("json"), ",")[0]
+	if len(name) == 0 || name == "-" {
+		return member.Name
+	}
+	return name
+}
+
+func trimComments(lines []string) []string {
+	trimmed := []string{}
+	for _, l := range lines {
+		trimmed = append(trimmed, strings.TrimSpace(l))
+	}
+	return trimmed
+}
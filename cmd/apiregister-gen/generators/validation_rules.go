@@ -0,0 +1,196 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generators
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/gengo/types"
+)
+
+// ValidationRule is an expression of a `+validation:rule="<expr>",message="<message>"` comment
+// tag, compiled once into a package variable by validators.MustCompileRule
+type ValidationRule struct {
+	// Name is the name of the package variable holding the compiled rule
+	Name string
+	// Rule is the quoted expression
+	Rule string
+	// Message is the quoted message of the error reported when the rule evaluates to false
+	Message string
+}
+
+// StructValidationRules are the +validation:rule comment tags of a versioned struct and its
+// fields, served in its OpenAPI definition
+type StructValidationRules struct {
+	// Name is the name of the struct - e.g. UniversitySpec
+	Name string
+	// Fields are the rules by json name of the field, the rules of the struct itself have an
+	// empty name
+	Fields []*FieldValidationRules
+}
+
+// FieldValidationRules are the +validation:rule comment tags of a field of a versioned struct
+type FieldValidationRules struct {
+	JSONName string
+	Rules    []*ValidationRule
+}
+
+// parseValidationRule parses the value of a +validation:rule comment tag, a quoted expression
+// optionally followed by ,message= and a quoted message. It returns the expression, the message
+// and whether the expression refers to oldSelf.
+func parseValidationRule(value string) (string, string, bool, error) {
+	end := quotedEnd(value)
+	if end < 0 {
+		return "", "", false, fmt.Errorf("expected a quoted expression, got %s", value)
+	}
+	expression, err := strconv.Unquote(value[:end])
+	if err != nil {
+		return "", "", false, err
+	}
+	message := ""
+	if rest := value[end:]; len(rest) > 0 {
+		if !strings.HasPrefix(rest, ",message=") {
+			return "", "", false, fmt.Errorf("expected ,message= after the expression, got %s", rest)
+		}
+		if message, err = strconv.Unquote(strings.TrimPrefix(rest, ",message=")); err != nil {
+			return "", "", false, fmt.Errorf("expected a quoted message: %v", err)
+		}
+	}
+
+	// The expression is evaluated by pkg/validators, only check that it parses and whether it
+	// compares the value to the value before an update
+	expr, err := parser.ParseExpr(expression)
+	if err != nil {
+		return "", "", false, fmt.Errorf("failed to parse %q: %v", expression, err)
+	}
+	usesOldSelf := false
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			usesOldSelf = usesOldSelf || n.Name == "oldSelf"
+		case *ast.SelectorExpr:
+			// The selected field isn't an identifier of the rule
+			ast.Inspect(n.X, visit)
+			return false
+		}
+		return true
+	}
+	ast.Inspect(expr, visit)
+	return expression, message, usesOldSelf, nil
+}
+
+// quotedEnd returns the index following the double quoted string at the start of s, or -1
+func quotedEnd(s string) int {
+	if !strings.HasPrefix(s, `"`) {
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseValidationRules returns the statements evaluating the +validation:rule comment tags of
+// comments against value at path. The rules referring to oldSelf are evaluated against old on
+// update only, the others on create and update. owner names the rules in error messages and
+// prefixes the names of their package variables.
+func (apigroup *APIGroup) parseValidationRules(owner string, comments Comments,
+	value, old, path string) ([]string, []string) {
+	checks, updateChecks := []string{}, []string{}
+	for i, v := range comments.GetTags("validation:rule", "=") {
+		expression, message, usesOldSelf, err := parseValidationRule(v)
+		if err != nil {
+			// Reported with the OpenAPI validation rules
+			continue
+		}
+		rule := &ValidationRule{
+			Name:    fmt.Sprintf("%sRule%d", strings.ToLower(owner[:1])+strings.Replace(owner[1:], ".", "", -1), i),
+			Rule:    strconv.Quote(expression),
+			Message: strconv.Quote(message),
+		}
+		apigroup.ValidationRules = append(apigroup.ValidationRules, rule)
+		if usesOldSelf {
+			updateChecks = append(updateChecks, fmt.Sprintf(
+				"errs = append(errs, %s.Validate(%s, %s, %s)...)", rule.Name, value, old, path))
+		} else {
+			checks = append(checks, fmt.Sprintf(
+				"errs = append(errs, %s.Validate(%s, nil, %s)...)", rule.Name, value, path))
+		}
+	}
+	return checks, updateChecks
+}
+
+// ParseValidationRules parses the +validation:rule comment tags on the structs of the versioned
+// package and their fields, which are listed in the OpenAPI definitions of the structs
+func (apiversion *APIVersion) ParseValidationRules() {
+	if apiversion.Pkg == nil {
+		return
+	}
+	names := []string{}
+	for name := range apiversion.Pkg.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := apiversion.Pkg.Types[name]
+		if t.Kind != types.Struct {
+			continue
+		}
+		s := &StructValidationRules{Name: t.Name.Name}
+		add := func(field, jsonName string, comments Comments) {
+			f := &FieldValidationRules{JSONName: jsonName}
+			for _, v := range comments.GetTags("validation:rule", "=") {
+				expression, message, _, err := parseValidationRule(v)
+				if err != nil {
+					fieldError(t, field, "Invalid marker +validation:rule=%s: %v", v, err)
+					continue
+				}
+				f.Rules = append(f.Rules, &ValidationRule{Rule: strconv.Quote(expression), Message: strconv.Quote(message)})
+			}
+			if len(f.Rules) > 0 {
+				s.Fields = append(s.Fields, f)
+			}
+		}
+		add("", "", typeComments(t))
+		for _, member := range t.Members {
+			if !member.Embedded {
+				add(member.Name, jsonName(member), Comments(trimComments(member.CommentLines)))
+			}
+		}
+		if len(s.Fields) > 0 {
+			apiversion.ValidationRules = append(apiversion.ValidationRules, s)
+		}
+	}
+}
+
+// typeComments returns the comment tags of a type, from the comment right above it and the
+// comment above that
+func typeComments(t *types.Type) Comments {
+	return Comments(trimComments(append(append([]string{}, t.SecondClosestCommentLines...), t.CommentLines...)))
+}
@@ -19,6 +19,7 @@ package generators
 import (
 	"io"
 	"k8s.io/gengo/namer"
+	"strconv"
 	"text/template"
 
 	"k8s.io/gengo/generator"
@@ -42,7 +43,7 @@ func CreateVersionedGenerator(apiversion *APIVersion, apigroup *APIGroup, filena
 
 func hasSubresources(version *APIVersion) bool {
 	for _, v := range version.Resources {
-		if len(v.Subresources) != 0 {
+		if len(v.Subresources) != 0 || v.Scale != nil {
 			return true
 		}
 	}
@@ -52,6 +53,7 @@ func hasSubresources(version *APIVersion) bool {
 func (d *versionedGenerator) Imports(c *generator.Context) []string {
 	imports := []string{
 		"metav1 \"k8s.io/apimachinery/pkg/apis/meta/v1\"",
+		"autoscalingv1 \"k8s.io/api/autoscaling/v1\"",
 		"k8s.io/apimachinery/pkg/runtime",
 		"sigs.k8s.io/apiserver-builder-alpha/pkg/builders",
 		"k8s.io/apimachinery/pkg/runtime/schema",
@@ -61,6 +63,9 @@ func (d *versionedGenerator) Imports(c *generator.Context) []string {
 	if hasSubresources(d.apiversion) {
 		imports = append(imports, "k8s.io/apiserver/pkg/registry/rest")
 	}
+	if d.apiversion.DefaultsUseJSON {
+		imports = append(imports, "encoding/json")
+	}
 
 	return imports
 }
@@ -68,6 +73,7 @@ func (d *versionedGenerator) Imports(c *generator.Context) []string {
 func (d *versionedGenerator) Finalize(context *generator.Context, w io.Writer) error {
 	temp := template.Must(template.New("versioned-template").Funcs(map[string]interface{}{
 		"public": namer.IC,
+		"quote":  strconv.Quote,
 	}).Parse(VersionedAPITemplate))
 	return temp.Execute(w, d.apiversion)
 }
@@ -87,6 +93,39 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	return nil
 }
 
+// addFieldLabelConversionFuncs accepts the fields declared with +selectablefield in field selectors
+func addFieldLabelConversionFuncs(scheme *runtime.Scheme) error {
+{{ range $api := .Resources -}}
+{{ if $api.SelectableFields -}}
+	if err := scheme.AddFieldLabelConversionFunc(
+		SchemeGroupVersion.WithKind("{{ $api.Kind }}"), {{ $api.Kind }}SchemeFns{}.FieldSelectorConversion); err != nil {
+		return err
+	}
+{{ end -}}
+{{ end -}}
+	return nil
+}
+
+{{ range $api := .Resources -}}
+{{ if $api.SelectableFields -}}
+// {{ $api.Kind }}SchemeFns converts the field selectors of {{ $api.Kind }}
+// +k8s:deepcopy-gen=false
+type {{ $api.Kind }}SchemeFns struct {
+	builders.DefaultSchemeFns
+}
+
+// FieldSelectorConversion accepts the metadata name and namespace and the fields declared with
+// +selectablefield
+func ({{ $api.Kind }}SchemeFns) FieldSelectorConversion(label, value string) (string, string, error) {
+	switch label {
+	case {{ range $i, $f := $api.SelectableFields }}{{ if $i }}, {{ end }}{{ quote $f.Label }}{{ end }}:
+		return label, value, nil
+	}
+	return runtime.DefaultMetaV1FieldSelectorConversion(label, value)
+}
+
+{{ end -}}
+{{ end -}}
 var (
 	ApiVersion = builders.NewApiVersion("{{.Group}}.{{.Domain}}", "{{.Version}}").WithResources(
 		{{ range $api := .Resources -}}
@@ -97,10 +136,10 @@ var (
 			func() runtime.Object { return &{{ $api.Kind }}{} },     // Register versioned resource
 			func() runtime.Object { return &{{ $api.Kind }}List{} }, // Register versioned resource list
 			&{{ $api.Group }}.{{ $api.StatusStrategy }}{DefaultStatusStorageStrategy: builders.StatusStorageStrategySingleton},
-		),{{ end -}}
+		){{ if $api.StorageBackend }}.WithStorageBackend("{{ $api.StorageBackend }}"){{ end }},{{ end -}}
 
 		{{ range $subresource := $api.Subresources -}}
-		builders.NewApiResourceWithStorage(
+		builders.{{ if $subresource.RESTContext }}NewApiResourceWithRESTContext{{ else }}NewApiResourceWithStorage{{ end }}(
 			{{ $api.Group }}.Internal{{ $subresource.Kind }}REST,
 			func() runtime.Object { return &{{ $subresource.Request }}{} }, // Register versioned resource
 			nil,
@@ -109,8 +148,42 @@ var (
 			{{ end -}}
 		),
 		{{ end -}}
+		{{ if $api.Scale -}}
+		builders.NewApiResourceWithStorage(
+			{{ $api.Group }}.Internal{{ $api.Kind }}Scale,
+			func() runtime.Object { return &autoscalingv1.Scale{} },
+			nil,
+			func(generic.RESTOptionsGetter) rest.Storage {
+				return builders.NewScaleREST({{ $api.Group }}.{{ $api.Group|public }}{{ $api.Kind }}Storage, builders.ScaleSubresource{
+					SpecReplicasPath:   "{{ $api.Scale.SpecReplicasPath }}",
+					StatusReplicasPath: "{{ $api.Scale.StatusReplicasPath }}",
+					LabelSelectorPath:  "{{ $api.Scale.LabelSelectorPath }}",
+				})
+			},
+		),
+		{{ end -}}
 		{{ end -}}
-	)
+	){{ if .Defaults }}.WithOpenAPIDefaults(map[string]map[string]string{
+		{{ range $d := .Defaults -}}
+		"{{ $.Pkg.Path }}.{{ $d.Name }}": {
+			{{ range $f := $d.Fields -}}
+			"{{ $f.JSONName }}": {{ $f.Value }},
+			{{ end -}}
+		},
+		{{ end -}}
+	}){{ end }}{{ if .ValidationRules }}.WithOpenAPIValidationRules(map[string]map[string][]builders.ValidationRule{
+		{{ range $s := .ValidationRules -}}
+		"{{ $.Pkg.Path }}.{{ $s.Name }}": {
+			{{ range $f := $s.Fields -}}
+			"{{ $f.JSONName }}": {
+				{{ range $r := $f.Rules -}}
+				{Rule: {{ $r.Rule }}, Message: {{ $r.Message }}},
+				{{ end -}}
+			},
+			{{ end -}}
+		},
+		{{ end -}}
+	}){{ end }}
 
 	// Required by code generated by go2idl
 	AddToScheme = (&runtime.SchemeBuilder{
@@ -118,6 +191,7 @@ var (
 		RegisterDefaults, 
 		RegisterConversions,
 		addKnownTypes,
+		addFieldLabelConversionFuncs,
 		func(scheme *runtime.Scheme) error {
 			metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 			return nil
@@ -141,6 +215,15 @@ func Resource(resource string) schema.GroupResource {
 	return SchemeGroupVersion.WithResource(resource).GroupResource()
 }
 
+{{ range $d := .Defaults -}}
+// SetDefaults_{{ $d.Name }} sets the defaults of the +default comment tags of {{ $d.Name }}
+func SetDefaults_{{ $d.Name }}(obj *{{ $d.Name }}) {
+	{{ range $s := $d.Statements -}}
+	{{ $s }}
+	{{ end -}}
+}
+
+{{ end -}}
 {{ range $api := .Resources -}}
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -17,9 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
 	"os"
 	"runtime"
 
+	"github.com/spf13/pflag"
 	"k8s.io/gengo/args"
 	"k8s.io/klog"
 	"sigs.k8s.io/apiserver-builder-alpha/cmd/apiregister-gen/generators"
@@ -39,10 +41,21 @@ func main() {
 	// Custom args.
 	customArgs := &generators.CustomArgs{}
 	arguments.CustomArgs = customArgs
+	pflag.CommandLine.StringVar(&customArgs.APIsPackage, "apis-package", "",
+		"apis package aggregating the groups of every apis package of the input, required if the input has several apis packages")
+	pflag.CommandLine.BoolVar(&customArgs.Lint, "lint", false,
+		"only validate the comment tags of the input, without generating code")
 
 	g := generators.Gen{}
 	if err := g.Execute(arguments); err != nil {
 		klog.Fatalf("Error: %v", err)
 	}
+	if diagnostics := generators.Diagnostics(); len(diagnostics) > 0 {
+		for _, d := range diagnostics {
+			fmt.Fprintln(os.Stderr, d)
+		}
+		fmt.Fprintf(os.Stderr, "Found %d invalid comment tags\n", len(diagnostics))
+		os.Exit(1)
+	}
 	klog.V(2).Info("Completed successfully.")
 }
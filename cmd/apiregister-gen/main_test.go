@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const testdata = "sigs.k8s.io/apiserver-builder-alpha/cmd/apiregister-gen/generators/testdata"
+
+// TestMain runs apiregister-gen with the arguments of APIREGISTER_GEN_ARGS instead of the tests,
+// lint runs the test binary this way to check the exit code of apiregister-gen
+func TestMain(m *testing.M) {
+	if args := os.Getenv("APIREGISTER_GEN_ARGS"); len(args) > 0 {
+		os.Args = append([]string{"apiregister-gen"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// lint runs apiregister-gen --lint on the apis packages of the fixture
+func lint(fixture string) (string, error) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "APIREGISTER_GEN_ARGS=--lint --input-dirs "+testdata+"/"+fixture+"/apis/...")
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return stderr.String(), err
+}
+
+func TestLintExitCode(t *testing.T) {
+	stderr, err := lint("invalid")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("apiregister-gen --lint = %v, want exit code 1\n%s", err, stderr)
+	}
+	for _, want := range []string{
+		"generators/testdata/invalid/apis/widgets/v1/widget_types.go:29: Widget: +printcolumn:",
+		"generators/testdata/invalid/apis/widgets/v1/widget_types.go:39: WidgetSpec.Size: Invalid validation marker:",
+		"Found 2 invalid comment tags",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("apiregister-gen --lint printed:\n%s\nwant %q", stderr, want)
+		}
+	}
+
+	if stderr, err := lint("valid"); err != nil {
+		t.Errorf("apiregister-gen --lint = %v, want exit code 0\n%s", err, stderr)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"k8s.io/klog"
@@ -163,7 +164,7 @@ func GoBuild(cmd *cobra.Command, args []string) {
 	if buildApiserver() {
 		// Build the apiserver
 		path := filepath.Join("cmd", "apiserver", "main.go")
-		c := exec.Command("go", "build", "-o", filepath.Join(outputdir, "apiserver"), path)
+		c := exec.Command("go", "build", "-ldflags", versionLdflags(), "-o", filepath.Join(outputdir, "apiserver"), path)
 		c.Env = append(os.Environ(), "CGO_ENABLED=0")
 		klog.Infof("CGO_ENABLED=0")
 		if len(goos) > 0 {
@@ -225,3 +226,24 @@ func buildController() bool {
 	}
 	return false
 }
+
+// versionLdflags returns the -ldflags setting the build metadata served on /version by the
+// apiserver. The git metadata is omitted outside of a git repository.
+func versionLdflags() string {
+	const pkg = "sigs.k8s.io/apiserver-builder-alpha/pkg/version"
+	flags := []string{
+		fmt.Sprintf("-X %s.buildDate=%s", pkg, time.Now().UTC().Format("2006-01-02T15:04:05Z")),
+	}
+	commit, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		klog.Warningf("skip the git metadata of the build: %v", err)
+		return strings.Join(flags, " ")
+	}
+	flags = append(flags, fmt.Sprintf("-X %s.gitCommit=%s", pkg, strings.TrimSpace(string(commit))))
+	treeState := "clean"
+	if status, err := exec.Command("git", "status", "--porcelain").Output(); err != nil || len(status) > 0 {
+		treeState = "dirty"
+	}
+	flags = append(flags, fmt.Sprintf("-X %s.gitTreeState=%s", pkg, treeState))
+	return strings.Join(flags, " ")
+}
@@ -81,6 +81,7 @@ apiserver-boot build docs --generate-toc=false
 var operations, buildOpenapi, generateToc bool
 var server string
 var disableDelegatedAuth bool
+var storageBackend string
 var cleanup bool
 var outputDir string
 
@@ -91,6 +92,7 @@ func AddDocs(cmd *cobra.Command) {
 	docsCmd.Flags().BoolVar(&operations, "operations", false, "if true, include operations in docs.")
 	docsCmd.Flags().BoolVar(&generateToc, "generate-toc", true, "If true, generate the table of contents from the api groups instead of using a statically configured ToC.")
 	docsCmd.Flags().BoolVar(&disableDelegatedAuth, "disable-delegated-auth", true, "If true, disable delegated auth in the apiserver with --delegated-auth=false.")
+	docsCmd.Flags().StringVar(&storageBackend, "storage-backend", "memory", "Storage backend of the server run to get swagger.json. If set to 'etcd3', a local etcd is started.")
 	docsCmd.Flags().StringVar(&outputDir, "output-dir", "docs", "Build docs into this directory")
 	cmd.AddCommand(docsCmd)
 	docsCmd.AddCommand(docsCleanCmd)
@@ -123,7 +125,6 @@ func RunDocs(cmd *cobra.Command, args []string) {
 	// Build the swagger.json
 	if buildOpenapi {
 		flags := []string{
-			"--etcd-servers=http://localhost:2379",
 			"--secure-port=9443",
 			"--print-openapi",
 		}
@@ -132,9 +133,14 @@ func RunDocs(cmd *cobra.Command, args []string) {
 			flags = append(flags, "--delegated-auth=false")
 		}
 
-		etcdStopFunc := RunEtcd()
-		defer etcdStopFunc()
-		klog.Infof("starting local etcd...")
+		if storageBackend == "memory" {
+			flags = append(flags, "--storage-backend=memory")
+		} else {
+			flags = append(flags, "--etcd-servers=http://localhost:2379")
+			etcdStopFunc := RunEtcd()
+			defer etcdStopFunc()
+			klog.Infof("starting local etcd...")
+		}
 
 		c := exec.Command(server,
 			flags...,
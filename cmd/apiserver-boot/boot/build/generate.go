@@ -38,6 +38,7 @@ var codegenerators []string
 var copyright string
 var generators = sets.String{}
 var vendorDir string
+var apisRoots []string
 
 var generateCmd = &cobra.Command{
 	Use:   "generated",
@@ -58,6 +59,7 @@ func AddGenerate(cmd *cobra.Command) {
 	generateCmd.Flags().StringVar(&copyright, "copyright", "boilerplate.go.txt", "Location of copyright boilerplate file.")
 	generateCmd.Flags().StringVar(&vendorDir, "vendor-dir", "", "Location of directory containing vendor files.")
 	generateCmd.Flags().StringArrayVar(&versionedAPIs, "api-versions", []string{}, "API version to generate code for.  Can be specified multiple times.  e.g. --api-versions foo/v1beta1 --api-versions bar/v1  defaults to all versions found under directories pkg/apis/<group>/<version>")
+	generateCmd.Flags().StringArrayVar(&apisRoots, "apis-root", []string{}, "Go package of an apis directory, with its own +domain, whose groups are served along with the groups of pkg/apis.  Can be specified multiple times.  e.g. --apis-root github.com/other/project/pkg/apis")
 	generateCmd.Flags().StringArrayVar(&codegenerators, "generator", []string{}, "list of generators to run.  e.g. --generator apiregister --generator conversion Valid values: [apiregister,conversion,client,deepcopy,defaulter,openapi,protobuf]")
 	generateCmd.AddCommand(generateCleanCmd)
 
@@ -123,11 +125,30 @@ func RunGenerate(cmd *cobra.Command, args []string) {
 		u = filepath.Join(util.Repo, "pkg", "apis", u)
 		unversioned = append(unversioned, "--input-dirs", u)
 	}
+	// The versions of the other apis roots are generated along with the versions of pkg/apis, the
+	// clients only cover pkg/apis
+	for _, r := range apisRoots {
+		groups := map[string]bool{}
+		for _, v := range findApiVersions(filepath.Join(util.GoSrc, r)) {
+			all = append(all, "--input-dirs", filepath.Join(r, v))
+			groups[path.Dir(v)] = true
+		}
+		for g := range groups {
+			unversioned = append(unversioned, "--input-dirs", filepath.Join(r, g))
+		}
+	}
 
 	if doGen("apiregister-gen") {
 		inputDirsArgs := []string{
 			"--input-dirs", filepath.Join(util.Repo, "pkg", "apis", "..."),
 		}
+		if len(apisRoots) > 0 {
+			// The generated GetAllApiBuilders of pkg/apis returns the groups of every apis root
+			for _, r := range apisRoots {
+				inputDirsArgs = append(inputDirsArgs, "--input-dirs", filepath.Join(r, "..."))
+			}
+			inputDirsArgs = append(inputDirsArgs, "--apis-package", filepath.Join(util.Repo, "pkg", "apis"))
+		}
 		controllerPkgs := filepath.Join(util.Repo, "pkg", "controller", "...")
 		if _, err := os.Stat(filepath.Join(util.GoSrc, util.Repo, "pkg", "controller")); err == nil {
 			inputDirsArgs = append(inputDirsArgs, "--input-dirs", controllerPkgs)
@@ -182,6 +203,7 @@ func RunGenerate(cmd *cobra.Command, args []string) {
 			"k8s.io/apimachinery/pkg/util/intstr",
 			"k8s.io/api/core/v1",
 			"k8s.io/api/apps/v1",
+			"k8s.io/api/autoscaling/v1",
 		}
 
 		// Add any vendored apis from core
@@ -360,24 +382,7 @@ func getVendorApis(pkg string) []string {
 
 func initApis() {
 	if len(versionedAPIs) == 0 {
-		groups, err := ioutil.ReadDir(filepath.Join("pkg", "apis"))
-		if err != nil {
-			klog.Fatalf("could not read pkg/apis directory to find api Versions")
-		}
-		for _, g := range groups {
-			if g.IsDir() {
-				versionFiles, err := ioutil.ReadDir(filepath.Join("pkg", "apis", g.Name()))
-				if err != nil {
-					klog.Fatalf("could not read pkg/apis/%s directory to find api Versions", g.Name())
-				}
-				versionMatch := regexp.MustCompile("^v\\d+(alpha\\d+|beta\\d+)*$")
-				for _, v := range versionFiles {
-					if v.IsDir() && versionMatch.MatchString(v.Name()) {
-						versionedAPIs = append(versionedAPIs, filepath.Join(g.Name(), v.Name()))
-					}
-				}
-			}
-		}
+		versionedAPIs = findApiVersions(filepath.Join("pkg", "apis"))
 	}
 	u := map[string]bool{}
 	for _, a := range versionedAPIs {
@@ -387,3 +392,27 @@ func initApis() {
 		unversionedAPIs = append(unversionedAPIs, a)
 	}
 }
+
+// findApiVersions returns the <group>/<version> directories of the apis directory dir
+func findApiVersions(dir string) []string {
+	versions := []string{}
+	groups, err := ioutil.ReadDir(dir)
+	if err != nil {
+		klog.Fatalf("could not read %s directory to find api Versions", dir)
+	}
+	for _, g := range groups {
+		if g.IsDir() {
+			versionFiles, err := ioutil.ReadDir(filepath.Join(dir, g.Name()))
+			if err != nil {
+				klog.Fatalf("could not read %s/%s directory to find api Versions", dir, g.Name())
+			}
+			versionMatch := regexp.MustCompile("^v\\d+(alpha\\d+|beta\\d+)*$")
+			for _, v := range versionFiles {
+				if v.IsDir() && versionMatch.MatchString(v.Name()) {
+					versions = append(versions, filepath.Join(g.Name(), v.Name()))
+				}
+			}
+		}
+	}
+	return versions
+}
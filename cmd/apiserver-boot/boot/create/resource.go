@@ -294,10 +294,11 @@ import (
 )
 
 // Validate checks that an instance of {{.Kind}} is well formed
-func ({{.Kind}}Strategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+func (s {{.Kind}}Strategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
 	o := obj.(*{{.Kind}})
 	klog.V(5).Infof("Validating fields for {{.Kind}} %s", o.Name)
-	errors := field.ErrorList{}
+	// errors from the +validation comment tags on the {{.Kind}} fields
+	errors := s.DefaultStorageStrategy.Validate(ctx, obj)
 	// perform validation here and add to errors using field.Invalid
 	return errors
 }
@@ -481,6 +482,7 @@ import (
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/apiserver/pkg/admission"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/cmd/server"
 )
 
 var _ admission.Interface 											= &{{ lower .Kind }}Plugin{}
@@ -490,6 +492,7 @@ var _ genericadmissioninitializer.WantsExternalKubeInformerFactory 	= &{{ lower
 var _ genericadmissioninitializer.WantsExternalKubeClientSet 		= &{{ lower .Kind }}Plugin{}
 var _ aggregatedadmission.WantsAggregatedResourceInformerFactory 	= &{{ lower .Kind }}Plugin{}
 var _ aggregatedadmission.WantsAggregatedResourceClientSet 			= &{{ lower .Kind }}Plugin{}
+var _ server.ConfigurableAdmissionPlugin 							= &{{ lower .Kind }}Plugin{}
 
 func New{{ .Kind }}Plugin() *{{ lower .Kind }}Plugin {
 	return &{{ lower .Kind }}Plugin{
@@ -499,6 +502,20 @@ func New{{ .Kind }}Plugin() *{{ lower .Kind }}Plugin {
 
 type {{ lower .Kind }}Plugin struct {
 	*admission.Handler
+	config *{{ .Kind }}PluginConfig
+}
+
+// {{ .Kind }}PluginConfig is decoded from the {{ .Kind }} section of the --admission-control-config-file
+type {{ .Kind }}PluginConfig struct {
+}
+
+func (p *{{ lower .Kind }}Plugin) NewConfig() interface{} {
+	return &{{ .Kind }}PluginConfig{}
+}
+
+func (p *{{ lower .Kind }}Plugin) SetConfig(config interface{}) error {
+	p.config = config.(*{{ .Kind }}PluginConfig)
+	return nil
 }
 
 func (p *{{ lower .Kind }}Plugin) ValidateInitialization() error {
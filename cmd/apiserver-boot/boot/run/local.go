@@ -53,6 +53,7 @@ kubectl --kubeconfig kubeconfig get <type>`,
 }
 
 var etcd string
+var storageBackend string
 var config string
 var printapiserver bool
 var printcontrollermanager bool
@@ -73,6 +74,7 @@ func AddLocal(cmd *cobra.Command) {
 	localCmd.Flags().StringVar(&server, "apiserver", "", "path to apiserver binary to run")
 	localCmd.Flags().StringVar(&controllermanager, "controller-manager", "", "path to controller-manager binary to run")
 	localCmd.Flags().StringVar(&etcd, "etcd", "", "if non-empty, use this etcd instead of starting a new one")
+	localCmd.Flags().StringVar(&storageBackend, "storage-backend", "etcd3", "storage backend of the apiserver, 'memory' keeps the resources in the apiserver process and doesn't start etcd")
 
 	localCmd.Flags().StringVar(&config, "config", "kubeconfig", "path to the kubeconfig to write for using kubectl")
 
@@ -111,7 +113,7 @@ func RunLocal(cmd *cobra.Command, args []string) {
 	}
 
 	// Start etcd
-	if _, f := r["etcd"]; f {
+	if _, f := r["etcd"]; f && !usesInMemoryStorage() {
 		etcd = "http://localhost:2379"
 		RunEtcd(ctx, cancel)
 		time.Sleep(time.Second * 2)
@@ -150,12 +152,17 @@ func RunApiserver(ctx context.Context, cancel context.CancelFunc) *exec.Cmd {
 	}
 
 	flags := []string{
-		fmt.Sprintf("--etcd-servers=%s", etcd),
 		fmt.Sprintf("--secure-port=%v", securePort),
 		fmt.Sprintf("--insecure-port=%v", insecurePort),
 		fmt.Sprintf("--insecure-bind-address=127.0.0.1"),
 	}
 
+	if usesInMemoryStorage() {
+		flags = append(flags, fmt.Sprintf("--storage-backend=%s", storageBackend))
+	} else {
+		flags = append(flags, fmt.Sprintf("--etcd-servers=%s", etcd))
+	}
+
 	if disableDelegatedAuth {
 		flags = append(flags, "--delegated-auth=false")
 	}
@@ -191,6 +198,11 @@ func RunControllerManager(ctx context.Context, cancel context.CancelFunc) *exec.
 	return controllerManagerCmd
 }
 
+// usesInMemoryStorage returns true if the apiserver runs without etcd
+func usesInMemoryStorage() bool {
+	return storageBackend == "memory"
+}
+
 // run a command via goroutine
 func runCommon(cmd *exec.Cmd, ctx context.Context, cancel context.CancelFunc) {
 	stopCh := make(chan error)
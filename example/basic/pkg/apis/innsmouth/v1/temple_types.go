@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// +k8s:openapi-gen=true
+// +resource:path=temples
+// +subresource:scale,specReplicasPath=.spec.worshippers,statusReplicasPath=.status.worshippers
+// +gracefuldeletion:gracePeriodSeconds=60
+// +printcolumn:name=Location,type=string,JSONPath=.spec.location
+// +printcolumn:name=HighPriest,type=string,JSONPath=.spec.high_priest,priority=1
+// +printcolumn:name=Worshippers,type=integer,JSONPath=.status.worshippers,description="The number of worshippers of the temple"
+// +printcolumn:name=Age,type=date,JSONPath=.metadata.creationTimestamp
+// +selectablefield:JSONPath=.spec.location,index=true
+// +selectablefield:JSONPath=.spec.high_priest
+// Temple defines a temple of the Esoteric Order of Dagon
+type Temple struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TempleSpec   `json:"spec,omitempty"`
+	Status TempleStatus `json:"status,omitempty"`
+}
+
+// TempleRite is the time at which the rites of the temple are held
+type TempleRite string
+
+const (
+	NightRite TempleRite = "Night"
+	DawnRite  TempleRite = "Dawn"
+)
+
+// TempleSpec defines the desired state of Temple
+type TempleSpec struct {
+	// location of the temple, temples don't move.
+	// +immutable
+	Location string `json:"location,omitempty"`
+
+	// high_priest of the temple, ordained once.
+	// +immutable:once-set
+	HighPriest string `json:"high_priest,omitempty"`
+
+	// worshippers is the desired number of worshippers of the temple.
+	Worshippers int32 `json:"worshippers,omitempty"`
+
+	// rite is the time at which the rites are held.
+	// +default="Night"
+	// +validation:Enum=Night;Dawn
+	Rite TempleRite `json:"rite,omitempty"`
+
+	// altar of the temple.
+	// +default={"deity":"Dagon"}
+	Altar *TempleAltar `json:"altar,omitempty"`
+}
+
+// TempleAltar defines the altar of a Temple
+type TempleAltar struct {
+	// deity worshipped at the altar, it can't be replaced.
+	// +immutable
+	Deity string `json:"deity,omitempty"`
+}
+
+// TempleStatus defines the observed state of Temple
+type TempleStatus struct {
+	// worshippers is the number of worshippers of the temple.
+	Worshippers int32 `json:"worshippers,omitempty"`
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	. "sigs.k8s.io/apiserver-builder-alpha/example/basic/pkg/apis/innsmouth/v1"
+	. "sigs.k8s.io/apiserver-builder-alpha/example/basic/pkg/client/clientset_generated/clientset/typed/innsmouth/v1"
+)
+
+// forbidden returns the cause of the rejection of an update changing the field at path
+func forbidden(path, detail string) metav1.StatusCause {
+	return metav1.StatusCause{
+		Type:    metav1.CauseType(field.ErrorTypeForbidden),
+		Message: "Forbidden: " + detail,
+		Field:   path,
+	}
+}
+
+// causes returns the causes of the rejection of a request
+func causes(err error) []metav1.StatusCause {
+	status, ok := err.(errors.APIStatus)
+	Expect(ok).To(BeTrue(), "unexpected error %v", err)
+	Expect(status.Status().Details).ShouldNot(BeNil())
+	return status.Status().Details.Causes
+}
+
+var _ = Describe("Temple", func() {
+	var instance Temple
+	var client TempleInterface
+	var noGracePeriod int64
+
+	BeforeEach(func() {
+		instance = Temple{}
+		instance.Name = "temple-1"
+		instance.Spec.Location = "new-church-green"
+		instance.Spec.Worshippers = 3
+		client = cs.InnsmouthV1().Temples("temple-test")
+	})
+
+	AfterEach(func() {
+		client.Delete(context.TODO(), instance.Name, metav1.DeleteOptions{GracePeriodSeconds: &noGracePeriod})
+	})
+
+	Describe("when sending a storage request", func() {
+		Context("for a valid config", func() {
+			It("should default the rite and the altar", func() {
+				By("returning success from the create request")
+				actual, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("defaulting the unset fields")
+				Expect(actual.Spec.Rite).To(Equal(NightRite))
+				Expect(actual.Spec.Altar).To(Equal(&TempleAltar{Deity: "Dagon"}))
+			})
+
+			It("should delete the temple gracefully", func() {
+				By("returning success from the create request")
+				_, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("keeping the item after the delete request")
+				err = client.Delete(context.TODO(), instance.Name, metav1.DeleteOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				actual, err := client.Get(context.TODO(), instance.Name, metav1.GetOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(actual.DeletionTimestamp).ShouldNot(BeNil())
+				Expect(*actual.DeletionGracePeriodSeconds).To(Equal(int64(60)))
+
+				By("removing the item for delete requests without a grace period")
+				err = client.Delete(context.TODO(), instance.Name, metav1.DeleteOptions{GracePeriodSeconds: &noGracePeriod})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = client.Get(context.TODO(), instance.Name, metav1.GetOptions{})
+				Expect(errors.IsNotFound(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("when listing a resource", func() {
+		Context("using field selectors", func() {
+			It("should find the matching objects", func() {
+				instance2 := Temple{}
+				instance2.Name = "temple-2"
+				instance2.Spec.Location = "devil-reef"
+				instance2.Spec.HighPriest = "obed-marsh"
+				defer client.Delete(context.TODO(), instance2.Name, metav1.DeleteOptions{GracePeriodSeconds: &noGracePeriod})
+
+				By("returning success from the create requests")
+				_, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = client.Create(context.TODO(), &instance2, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("returning the items of the indexed field")
+				result, err := client.List(context.TODO(), metav1.ListOptions{FieldSelector: "spec.location=devil-reef"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Items).To(HaveLen(1))
+				Expect(result.Items[0].Name).To(Equal(instance2.Name))
+
+				By("returning the items of the field")
+				result, err = client.List(context.TODO(), metav1.ListOptions{FieldSelector: "spec.high_priest!=obed-marsh"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Items).To(HaveLen(1))
+				Expect(result.Items[0].Name).To(Equal(instance.Name))
+
+				By("returning the items matching every field")
+				result, err = client.List(context.TODO(), metav1.ListOptions{
+					FieldSelector: "spec.location=devil-reef,spec.high_priest=zadok-allen",
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Items).To(BeEmpty())
+			})
+
+			It("should reject the fields that aren't selectable", func() {
+				_, err := client.List(context.TODO(), metav1.ListOptions{FieldSelector: "spec.worshippers=3"})
+				Expect(errors.IsBadRequest(err)).To(BeTrue(), "unexpected error %v", err)
+				Expect(err.Error()).To(ContainSubstring(`"spec.worshippers" is not a known field selector`))
+			})
+		})
+	})
+
+	Describe("when updating a resource", func() {
+		Context("changing an immutable field", func() {
+			It("should reject the update at the path of the field", func() {
+				By("returning success from the create request")
+				actual, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				By("rejecting a new location")
+				update := actual.DeepCopy()
+				update.Spec.Location = "devil-reef"
+				_, err = client.Update(context.TODO(), update, metav1.UpdateOptions{})
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "unexpected error %v", err)
+				Expect(causes(err)).To(ConsistOf(forbidden("spec.location", "field is immutable")))
+
+				By("rejecting a new deity of the altar")
+				update = actual.DeepCopy()
+				update.Spec.Altar.Deity = "Hydra"
+				_, err = client.Update(context.TODO(), update, metav1.UpdateOptions{})
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "unexpected error %v", err)
+				Expect(causes(err)).To(ConsistOf(forbidden("spec.altar.deity", "field is immutable")))
+
+				By("accepting changes of the mutable fields")
+				update = actual.DeepCopy()
+				update.Spec.Worshippers = 5
+				update.Spec.Rite = DawnRite
+				_, err = client.Update(context.TODO(), update, metav1.UpdateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("changing a once-set field", func() {
+			It("should reject the update once the field is set", func() {
+				By("returning success from the create request")
+				actual, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(actual.Spec.HighPriest).To(BeEmpty())
+
+				By("accepting the first high priest")
+				actual.Spec.HighPriest = "obed-marsh"
+				actual, err = client.Update(context.TODO(), actual, metav1.UpdateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(actual.Spec.HighPriest).To(Equal("obed-marsh"))
+
+				By("rejecting another high priest")
+				actual.Spec.HighPriest = "zadok-allen"
+				_, err = client.Update(context.TODO(), actual, metav1.UpdateOptions{})
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "unexpected error %v", err)
+				Expect(causes(err)).To(ConsistOf(forbidden("spec.high_priest", "field is immutable once set")))
+
+				By("rejecting the removal of the high priest")
+				actual.Spec.HighPriest = ""
+				_, err = client.Update(context.TODO(), actual, metav1.UpdateOptions{})
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "unexpected error %v", err)
+				Expect(causes(err)).To(ConsistOf(forbidden("spec.high_priest", "field is immutable once set")))
+			})
+		})
+	})
+})
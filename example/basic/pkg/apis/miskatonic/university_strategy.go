@@ -25,15 +25,13 @@ import (
 )
 
 // Resource Validation
-func (UniversityStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+func (s UniversityStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
 	university := obj.(*University)
 	klog.Infof("Validating University %s\n", university.Name)
-	errors := field.ErrorList{}
-	if university.Spec.MaxStudents == nil || *university.Spec.MaxStudents < 1 || *university.Spec.MaxStudents > 150 {
-		errors = append(errors, field.Invalid(
-			field.NewPath("spec", "MaxStudents"),
-			*university.Spec.MaxStudents,
-			"Must be between 1 and 150"))
+	// MaxStudents is validated by the +validation comment tags of the field
+	errors := s.DefaultStorageStrategy.Validate(ctx, obj)
+	if university.Spec.MaxStudents == nil {
+		errors = append(errors, field.Required(field.NewPath("spec", "max_students"), ""))
 	}
 	return errors
 }
@@ -40,12 +40,17 @@ type University struct {
 }
 
 // UniversitySpec defines the desired state of University
+// +validation:rule="oldSelf.maxStudents == nil || (self.maxStudents != nil && self.maxStudents >= oldSelf.maxStudents)",message="max_students may not decrease"
 type UniversitySpec struct {
-	// faculty_size defines the desired faculty size of the university.  Defaults to 15.
+	// faculty_size defines the desired faculty size of the university.
+	// +default=15
 	FacultySize int `json:"faculty_size,omitempty"`
 
-	// max_students defines the maximum number of enrolled students.  Defaults to 300.
+	// max_students defines the maximum number of enrolled students.
 	// +optional
+	// +default=15
+	// +validation:Minimum=1
+	// +validation:Maximum=150
 	MaxStudents *int `json:"max_students,omitempty"`
 
 	// The unversioned struct definition for this field must be manually defined in the group package
@@ -22,6 +22,7 @@ import (
 	. "sigs.k8s.io/apiserver-builder-alpha/example/basic/pkg/apis/miskatonic/v1beta1"
 	. "sigs.k8s.io/apiserver-builder-alpha/example/basic/pkg/client/clientset_generated/clientset/typed/miskatonic/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	. "github.com/onsi/ginkgo"
@@ -98,6 +99,23 @@ var _ = Describe("University", func() {
 				_, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
 				Expect(err).Should(HaveOccurred())
 			})
+
+			It("should fail if max_students decreases", func() {
+				client = cs.MiskatonicV1beta1().Universities("university-test-decrease")
+				actual, err := client.Create(context.TODO(), &instance, metav1.CreateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				val := 10
+				actual.Spec.MaxStudents = &val
+				_, err = client.Update(context.TODO(), actual, metav1.UpdateOptions{})
+				Expect(errors.IsInvalid(err)).To(BeTrue())
+				Expect(err.Error()).To(ContainSubstring("spec: Invalid value: \"object\": max_students may not decrease"))
+
+				val = 20
+				actual.Spec.MaxStudents = &val
+				_, err = client.Update(context.TODO(), actual, metav1.UpdateOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
 		})
 	})
 
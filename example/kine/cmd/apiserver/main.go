@@ -24,16 +24,24 @@ import (
 	_ "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "github.com/go-openapi/loads"
 
+	"github.com/rancher/kine/pkg/endpoint"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/cmd/server"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // Enable cloud provider auth
 
 	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/apis"
+	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/apis/sqlite"
 	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/openapi"
 )
 
 func main() {
 	version := "v0"
 
+	// Resources tagged with "+resource:storage=kine" are persisted to an embedded sqlite database
+	builders.RegisterStorageBackend("kine", sqlite.NewKineStorageBackend(endpoint.Config{
+		Listener: endpoint.KineSocket,
+	}))
+
 	err := server.StartApiServerWithOptions(&server.StartOptions{
 		EtcdPath:         "/registry/example.com",
 		Apis:             apis.GetAllApiBuilders(),
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rancher/kine/pkg/endpoint"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+)
+
+// NewKineStorageBackend returns a storage backend persisting resources to an embedded kine server.
+// The kine server is started the first time a resource requests its storage.
+func NewKineStorageBackend(config endpoint.Config) builders.StorageBackend {
+	listener := &kineListener{config: config}
+	return func(delegate generic.RESTOptionsGetter) generic.RESTOptionsGetter {
+		return &kineProxiedRESTOptionsGetter{
+			delegate: delegate,
+			listener: listener,
+		}
+	}
+}
+
+type kineListener struct {
+	config endpoint.Config
+
+	once       sync.Once
+	etcdConfig endpoint.ETCDConfig
+	err        error
+}
+
+func (l *kineListener) listen() (endpoint.ETCDConfig, error) {
+	l.once.Do(func() {
+		l.etcdConfig, l.err = endpoint.Listen(context.TODO(), l.config)
+	})
+	return l.etcdConfig, l.err
+}
+
+type kineProxiedRESTOptionsGetter struct {
+	delegate generic.RESTOptionsGetter
+	listener *kineListener
+}
+
+func (g *kineProxiedRESTOptionsGetter) GetRESTOptions(resource schema.GroupResource) (generic.RESTOptions, error) {
+	restOptions, err := g.delegate.GetRESTOptions(resource)
+	if err != nil {
+		return generic.RESTOptions{}, err
+	}
+	etcdConfig, err := g.listener.listen()
+	if err != nil {
+		return generic.RESTOptions{}, err
+	}
+
+	restOptions.StorageConfig.Transport.ServerList = etcdConfig.Endpoints
+	restOptions.StorageConfig.Transport.CAFile = etcdConfig.TLSConfig.CAFile
+	restOptions.StorageConfig.Transport.CertFile = etcdConfig.TLSConfig.CertFile
+	restOptions.StorageConfig.Transport.KeyFile = etcdConfig.TLSConfig.KeyFile
+	return restOptions, nil
+}
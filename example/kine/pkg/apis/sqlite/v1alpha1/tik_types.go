@@ -28,7 +28,7 @@ import (
 
 // Tik
 // +k8s:openapi-gen=true
-// +resource:path=tiks,strategy=TikStrategy,rest=TikREST
+// +resource:path=tiks,strategy=TikStrategy,storage=kine
 type Tik struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -21,10 +21,13 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/rancher/kine/pkg/endpoint"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/test"
 
 	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/apis"
+	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/apis/sqlite"
 	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/client/clientset_generated/clientset"
 	"sigs.k8s.io/apiserver-builder-alpha/example/kine/pkg/openapi"
 )
@@ -39,6 +42,9 @@ func TestV1alpha1(t *testing.T) {
 }
 
 var _ = BeforeSuite(func() {
+	builders.RegisterStorageBackend("kine", sqlite.NewKineStorageBackend(endpoint.Config{
+		Listener: endpoint.KineSocket,
+	}))
 	testenv = test.NewTestEnvironment(apis.GetAllApiBuilders(), openapi.GetOpenAPIDefinitions)
 	config = testenv.Start()
 	cs = clientset.NewForConfigOrDie(config)
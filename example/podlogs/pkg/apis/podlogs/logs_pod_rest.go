@@ -33,26 +33,22 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apiserver/pkg/endpoints/request"
-	"k8s.io/apiserver/pkg/registry/generic"
 	genericrest "k8s.io/apiserver/pkg/registry/generic/rest"
 	"k8s.io/apiserver/pkg/registry/rest"
-	"k8s.io/client-go/kubernetes"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
-	restclient "k8s.io/client-go/rest"
 	"sigs.k8s.io/apiserver-builder-alpha/example/podlogs/pkg/kubelet"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
 )
 
 var _ rest.GetterWithOptions = &PodLogsREST{}
 
-func NewPodLogsREST(getter generic.RESTOptionsGetter) rest.Storage {
-
-	inClusterClientConfig, err := restclient.InClusterConfig()
-	if err != nil {
-		panic(err)
+func NewPodLogsREST(ctx *builders.RESTContext) rest.Storage {
+	// The clients of the core cluster are built from the in-cluster config or --kubeconfig
+	if ctx.KubeClient == nil {
+		panic(fmt.Errorf("pods/logs requires a client of the core cluster, run in-cluster or with --kubeconfig"))
 	}
 
-	client := kubernetes.NewForConfigOrDie(inClusterClientConfig)
+	client := ctx.KubeClient
 	nodeConnGetter, err := kubelet.NewNodeConnectionInfoGetter(
 		func(name string) (*corev1.Node, error) {
 			return client.CoreV1().Nodes().Get(name, metav1.GetOptions{})
@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/go-logr/zapr v0.1.1 // indirect
+	github.com/go-openapi/spec v0.19.3
 	github.com/golang/protobuf v1.3.4 // indirect
 	github.com/google/go-cmp v0.3.1 // indirect
 	github.com/gorilla/websocket v1.4.1 // indirect
@@ -23,6 +24,7 @@ require (
 	k8s.io/apiserver v0.18.4
 	k8s.io/client-go v0.18.4
 	k8s.io/code-generator v0.18.4
+	k8s.io/component-base v0.18.4
 	k8s.io/gengo v0.0.0-20200114144118-36b2048a9120
 	k8s.io/klog v1.0.0
 	k8s.io/kube-aggregator v0.18.4
@@ -32,6 +34,7 @@ require (
 	sigs.k8s.io/controller-tools v0.1.12 // indirect
 	sigs.k8s.io/kubebuilder v1.0.8
 	sigs.k8s.io/testing_frameworks v0.1.1
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace sigs.k8s.io/apiserver-builder-alpha/test => ./test
@@ -17,11 +17,14 @@ limitations under the License.
 package apiserver
 
 import (
+	"net/http"
+
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/version"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 )
@@ -61,11 +64,42 @@ type Config struct {
 	InsecureServingInfo *genericapiserver.DeprecatedInsecureServingInfo
 
 	PostStartHooks map[string]genericapiserver.PostStartHookFunc
+
+	// Version is served on /version, it defaults to 1.0
+	Version *version.Info
+
+	// NonResourceHandlers serve the paths that aren't resources of the server
+	NonResourceHandlers []NonResourceHandler
+
+	// RESTContext is passed to the REST of the resources built with a NewRESTWithContextFunc,
+	// its RESTOptionsGetter defaults to the one of the RecommendedConfig
+	RESTContext *builders.RESTContext
 }
 
 // Server contains state for a Kubernetes cluster master/api server.
 type Server struct {
 	GenericAPIServer *genericapiserver.GenericAPIServer
+
+	// served are the resources recorded by the resource metrics
+	served sets.String
+	// nonResourceHandlers are installed on the NonGoRestfulMux of the GenericAPIServer
+	nonResourceHandlers nonResourceHandlers
+}
+
+// WithResourceMetrics records the metrics of the resources of the server, e.g. for a handler
+// chain built around its UnprotectedHandler
+func (s *Server) WithResourceMetrics(handler http.Handler) http.Handler {
+	return withResourceMetrics(handler, s.served)
+}
+
+// WithNonResourceHandlers serves the NonResourceHandlers of the server, e.g. for a handler chain
+// built around its UnprotectedHandler
+func (s *Server) WithNonResourceHandlers(handler http.Handler) http.Handler {
+	return s.nonResourceHandlers.withNonResourceHandlers(handler, s.nonGoRestfulMux)
+}
+
+func (s *Server) nonGoRestfulMux() http.Handler {
+	return s.GenericAPIServer.Handler.NonGoRestfulMux
 }
 
 type completedConfig struct {
@@ -74,10 +108,13 @@ type completedConfig struct {
 
 // Complete fills in any fields not set that are required to have valid data. It's mutating the receiver.
 func (c *Config) Complete() completedConfig {
-	c.RecommendedConfig.Config.Version = &version.Info{
-		Major: "1",
-		Minor: "0",
+	if c.Version == nil {
+		c.Version = &version.Info{
+			Major: "1",
+			Minor: "0",
+		}
 	}
+	c.RecommendedConfig.Config.Version = c.Version
 	return completedConfig{c}
 }
 
@@ -93,7 +130,58 @@ func (c *Config) SkipComplete() completedConfig {
 
 // NewFunc returns a new instance of Server from the given config.
 func (c completedConfig) New() (*Server, error) {
-	genericServer, err := c.Config.RecommendedConfig.Config.Complete(c.RecommendedConfig.SharedInformerFactory).
+	// The groups are built first so the checks of their storage are added to readyz
+	config := &c.RecommendedConfig.Config
+	restContext := builders.RESTContext{}
+	if c.RESTContext != nil {
+		restContext = *c.RESTContext
+	}
+	if restContext.RESTOptionsGetter == nil {
+		restContext.RESTOptionsGetter = config.RESTOptionsGetter
+	}
+	groups := []*genericapiserver.APIGroupInfo{}
+	for _, builder := range builders.APIGroupBuilders {
+		group := builder.BuildWithResourceConfig(&restContext, config.MergedResourceConfig)
+		if len(group.PrioritizedVersions) == 0 {
+			// Every version of the group is disabled
+			continue
+		}
+		config.ReadyzChecks = append(config.ReadyzChecks, builders.GetReadyzChecks(group)...)
+		groups = append(groups, group)
+	}
+
+	handlers := nonResourceHandlers(c.NonResourceHandlers)
+	if err := handlers.validate(); err != nil {
+		return nil, err
+	}
+	if len(handlers) > 0 {
+		if config.RequestInfoResolver == nil {
+			config.RequestInfoResolver = genericapiserver.NewRequestInfoResolver(config)
+		}
+		config.RequestInfoResolver = handlers.withRequestInfoResolver(config.RequestInfoResolver)
+		authorizer, err := handlers.withAuthorizer(config.Authorization.Authorizer)
+		if err != nil {
+			return nil, err
+		}
+		config.Authorization.Authorizer = authorizer
+		if config.OpenAPIConfig != nil {
+			config.OpenAPIConfig.PostProcessSpec = handlers.withOpenAPI(config.OpenAPIConfig.PostProcessSpec)
+		}
+	}
+
+	s := &Server{
+		served:              servedResources(groups),
+		nonResourceHandlers: handlers,
+	}
+	buildHandlerChain := config.BuildHandlerChainFunc
+	if buildHandlerChain == nil {
+		buildHandlerChain = genericapiserver.DefaultBuildHandlerChain
+	}
+	config.BuildHandlerChainFunc = func(apiHandler http.Handler, c *genericapiserver.Config) http.Handler {
+		return buildHandlerChain(s.WithNonResourceHandlers(s.WithResourceMetrics(apiHandler)), c)
+	}
+
+	genericServer, err := config.Complete(c.RecommendedConfig.SharedInformerFactory).
 		New("aggregated-apiserver", genericapiserver.NewEmptyDelegate()) // completion is done in Complete, no need for a second time
 	if err != nil {
 		return nil, err
@@ -103,12 +191,13 @@ func (c completedConfig) New() (*Server, error) {
 		genericServer.AddPostStartHookOrDie(hookName, hook)
 	}
 
-	s := &Server{
-		GenericAPIServer: genericServer,
+	s.GenericAPIServer = genericServer
+	if err := handlers.validatePaths(genericServer, config.OpenAPIConfig != nil); err != nil {
+		return nil, err
 	}
+	handlers.install(genericServer)
 
-	for _, builder := range builders.APIGroupBuilders {
-		group := builder.Build(c.RecommendedConfig.Config.RESTOptionsGetter)
+	for _, group := range groups {
 		if err := s.GenericAPIServer.InstallAPIGroup(group); err != nil {
 			return nil, err
 		}
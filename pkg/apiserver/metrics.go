@@ -0,0 +1,167 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/metrics"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	compbasemetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const resourceMetricsSubsystem = "apiserver_builder"
+
+var (
+	resourceLabels = []string{"group", "version", "resource", "subresource", "verb"}
+
+	resourceRequestLatency = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Subsystem:      resourceMetricsSubsystem,
+			Name:           "resource_request_duration_seconds",
+			Help:           "Latency of the REST of the resources, excluding authentication, authorization and the in-flight limits.",
+			Buckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		resourceLabels,
+	)
+	resourceRequestErrors = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Subsystem:      resourceMetricsSubsystem,
+			Name:           "resource_request_errors_total",
+			Help:           "Number of requests to the REST of the resources answered with an error code.",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		append(resourceLabels, "code"),
+	)
+	resourceResponseSizes = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Subsystem:      resourceMetricsSubsystem,
+			Name:           "resource_response_sizes",
+			Help:           "Size in bytes of the responses of the REST of the resources, including the errors.",
+			Buckets:        compbasemetrics.ExponentialBuckets(1000, 10, 7),
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		resourceLabels,
+	)
+	resourceWatches = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Subsystem:      resourceMetricsSubsystem,
+			Name:           "resource_watches",
+			Help:           "Number of open watches of the resources.",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"group", "version", "resource"},
+	)
+
+	registerResourceMetrics sync.Once
+)
+
+// servedResources are the group/version/resource[/subresource] paths of the storage of the
+// groups. Only their requests are recorded so that unknown paths don't grow the label values.
+func servedResources(groups []*genericapiserver.APIGroupInfo) sets.String {
+	served := sets.NewString()
+	for _, group := range groups {
+		for _, version := range group.PrioritizedVersions {
+			for resource := range group.VersionedResourcesStorageMap[version.Version] {
+				served.Insert(path.Join(version.Group, version.Version, resource))
+			}
+		}
+	}
+	return served
+}
+
+// withResourceMetrics records the latency, errors, response sizes and open watches of the
+// requests to the served resources. The handler must run after the request info is resolved,
+// i.e. as the api handler of the handler chain.
+//
+// The requests are recorded around the REST handlers rather than by wrapping the rest.Storage of
+// the resources: the endpoints installer serves the verbs of the optional interfaces implemented
+// by a storage, e.g. rest.Watcher or rest.Connecter, which a wrapper would have to mirror for
+// every custom REST. The handlers serve every storage registered by the builders the same way,
+// including the ones of NewApiResourceWithStorage and the subresources.
+func withResourceMetrics(handler http.Handler, served sets.String) http.Handler {
+	registerResourceMetrics.Do(func() {
+		legacyregistry.MustRegister(resourceRequestLatency, resourceRequestErrors, resourceResponseSizes, resourceWatches)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := request.RequestInfoFrom(req.Context())
+		if !ok || !info.IsResourceRequest ||
+			!served.Has(path.Join(info.APIGroup, info.APIVersion, info.Resource, info.Subresource)) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if info.Verb == "watch" {
+			watches := resourceWatches.WithLabelValues(info.APIGroup, info.APIVersion, info.Resource)
+			watches.Inc()
+			defer watches.Dec()
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		delegate := &metrics.ResponseWriterDelegator{ResponseWriter: w}
+		_, cn := w.(http.CloseNotifier)
+		_, fl := w.(http.Flusher)
+		_, hj := w.(http.Hijacker)
+		if cn && fl && hj {
+			w = &fancyResponseWriterDelegator{delegate}
+		} else {
+			w = delegate
+		}
+
+		start := time.Now()
+		handler.ServeHTTP(w, req)
+
+		labels := []string{info.APIGroup, info.APIVersion, info.Resource, info.Subresource, info.Verb}
+		resourceRequestLatency.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		if status := delegate.Status(); status >= http.StatusBadRequest {
+			resourceRequestErrors.WithLabelValues(append(labels, strconv.Itoa(status))...).Inc()
+		}
+		if delegate.ContentLength() > 0 {
+			resourceResponseSizes.WithLabelValues(labels...).Observe(float64(delegate.ContentLength()))
+		}
+	})
+}
+
+// fancyResponseWriterDelegator keeps the optional interfaces of the response writer, e.g. for
+// the upgraded connections of connect requests
+type fancyResponseWriterDelegator struct {
+	*metrics.ResponseWriterDelegator
+}
+
+func (f *fancyResponseWriterDelegator) CloseNotify() <-chan bool {
+	return f.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+func (f *fancyResponseWriterDelegator) Flush() {
+	f.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (f *fancyResponseWriterDelegator) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return f.ResponseWriter.(http.Hijacker).Hijack()
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// serveResource serves a request to the resource of info with the handler recorded by the
+// resource metrics
+func serveResource(served sets.String, info *request.RequestInfo, handler http.HandlerFunc) {
+	info.IsResourceRequest = true
+	req := httptest.NewRequest("GET", "/apis", nil)
+	req = req.WithContext(request.WithRequestInfo(req.Context(), info))
+	withResourceMetrics(handler, served).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+// scrape returns the metrics exported on /metrics
+func scrape(t *testing.T) string {
+	w := httptest.NewRecorder()
+	legacyregistry.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d, want 200", w.Code)
+	}
+	return w.Body.String()
+}
+
+func TestResourceMetrics(t *testing.T) {
+	served := sets.NewString("metrics.example.com/v1/widgets", "metrics.example.com/v1/widgets/status")
+	widgets := &request.RequestInfo{APIGroup: "metrics.example.com", APIVersion: "v1", Resource: "widgets"}
+	object := func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1500)))
+	}
+	notFound := func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(strings.Repeat("x", 200)))
+	}
+
+	get := *widgets
+	get.Verb = "get"
+	serveResource(served, &get, object)
+	serveResource(served, &get, notFound)
+	update := *widgets
+	update.Verb, update.Subresource = "update", "status"
+	serveResource(served, &update, object)
+	// The resources that aren't served aren't recorded
+	unknown := *widgets
+	unknown.Verb, unknown.Resource = "get", "gadgets"
+	serveResource(served, &unknown, object)
+
+	watch := *widgets
+	watch.Verb = "watch"
+	watching := make(chan struct{})
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveResource(served, &watch, func(w http.ResponseWriter, req *http.Request) {
+			close(watching)
+			<-stop
+		})
+	}()
+	<-watching
+
+	metrics := scrape(t)
+	for _, series := range []string{
+		`apiserver_builder_resource_request_duration_seconds_count{group="metrics.example.com",resource="widgets",subresource="",verb="get",version="v1"} 2`,
+		`apiserver_builder_resource_request_duration_seconds_count{group="metrics.example.com",resource="widgets",subresource="status",verb="update",version="v1"} 1`,
+		`apiserver_builder_resource_request_errors_total{code="404",group="metrics.example.com",resource="widgets",subresource="",verb="get",version="v1"} 1`,
+		// The sizes of the object and of the error
+		`apiserver_builder_resource_response_sizes_bucket{group="metrics.example.com",resource="widgets",subresource="",verb="get",version="v1",le="1000"} 1`,
+		`apiserver_builder_resource_response_sizes_sum{group="metrics.example.com",resource="widgets",subresource="",verb="get",version="v1"} 1700`,
+		`apiserver_builder_resource_response_sizes_count{group="metrics.example.com",resource="widgets",subresource="",verb="get",version="v1"} 2`,
+		`apiserver_builder_resource_response_sizes_sum{group="metrics.example.com",resource="widgets",subresource="status",verb="update",version="v1"} 1500`,
+		`apiserver_builder_resource_watches{group="metrics.example.com",resource="widgets",version="v1"} 1`,
+	} {
+		if !strings.Contains(metrics, series+"\n") {
+			t.Errorf("missing series %s", series)
+		}
+	}
+	for _, unexpected := range []string{
+		`resource="gadgets"`,
+		`apiserver_builder_resource_request_errors_total{code="404",group="metrics.example.com",resource="widgets",subresource="status"`,
+	} {
+		if strings.Contains(metrics, unexpected) {
+			t.Errorf("unexpected series %s", unexpected)
+		}
+	}
+
+	close(stop)
+	<-done
+	metrics = scrape(t)
+	if series := `apiserver_builder_resource_watches{group="metrics.example.com",resource="widgets",version="v1"} 0`; !strings.Contains(metrics, series+"\n") {
+		t.Errorf("missing series %s after the watch ended", series)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/authorization/path"
+	"k8s.io/apiserver/pkg/authorization/union"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+)
+
+// NonResourceHandler serves a path that isn't a resource of the server, e.g. a webhook below
+// the path of a group version or a debug endpoint. The requests go through the same
+// authentication, authorization and audit as the requests to the resources.
+type NonResourceHandler struct {
+	// Path is the path served by the handler, a path ending in / serves the paths below it too
+	Path    string
+	Handler http.Handler
+
+	// AlwaysAllow skips the authorization of the requests to the path, like
+	// --authorization-always-allow-paths. The requests are still authenticated and audited.
+	AlwaysAllow bool
+
+	// OpenAPI describes the operations of the path in the served OpenAPI spec. This field is
+	// optional, the path isn't listed by default.
+	OpenAPI *spec.PathItem
+}
+
+// matches returns true if the handler serves the path
+func (h NonResourceHandler) matches(path string) bool {
+	if strings.HasSuffix(h.Path, "/") {
+		return strings.HasPrefix(path, h.Path)
+	}
+	return path == h.Path
+}
+
+// nonResourceHandlers are the NonResourceHandlers of a server
+type nonResourceHandlers []NonResourceHandler
+
+func (handlers nonResourceHandlers) validate() error {
+	paths := sets.NewString()
+	for _, h := range handlers {
+		if !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("the path %q of a non-resource handler must start with /", h.Path)
+		}
+		if h.Handler == nil {
+			return fmt.Errorf("the non-resource handler of %s is nil", h.Path)
+		}
+		if paths.Has(h.Path) {
+			return fmt.Errorf("multiple non-resource handlers registered for path %s", h.Path)
+		}
+		paths.Insert(h.Path)
+	}
+	return nil
+}
+
+// reservedPaths are the paths the generic server registers once it's prepared to run, after the
+// handlers are installed
+var reservedPaths = []string{"/healthz", "/livez", "/readyz"}
+
+// validatePaths returns an error if a handler is registered for a path of the generic server,
+// registering it on the NonGoRestfulMux would panic
+func (handlers nonResourceHandlers) validatePaths(server *genericapiserver.GenericAPIServer, openAPI bool) error {
+	// The listed paths of the server are the paths of its NonGoRestfulMux and go-restful container
+	paths := sets.NewString(server.ListedPaths()...)
+	paths.Insert(reservedPaths...)
+	if openAPI {
+		paths.Insert("/openapi/v2")
+	}
+	for _, h := range handlers {
+		if paths.Has(h.Path) {
+			return fmt.Errorf("the path %s of a non-resource handler is served by the server", h.Path)
+		}
+		// The checks are registered below the paths of the health endpoints
+		for _, reserved := range reservedPaths {
+			if strings.HasPrefix(h.Path, reserved+"/") {
+				return fmt.Errorf("the path %s of a non-resource handler is served by the server", h.Path)
+			}
+		}
+	}
+	return nil
+}
+
+func (handlers nonResourceHandlers) matches(path string) bool {
+	for _, h := range handlers {
+		if h.matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// install registers the handlers on the NonGoRestfulMux of the server
+func (handlers nonResourceHandlers) install(server *genericapiserver.GenericAPIServer) {
+	for _, h := range handlers {
+		if strings.HasSuffix(h.Path, "/") {
+			server.Handler.NonGoRestfulMux.HandlePrefix(h.Path, h.Handler)
+		} else {
+			server.Handler.NonGoRestfulMux.Handle(h.Path, h.Handler)
+		}
+	}
+}
+
+// withRequestInfoResolver resolves the requests to the handlers as non-resource requests, the
+// paths below a group version would be taken for resources otherwise
+func (handlers nonResourceHandlers) withRequestInfoResolver(resolver request.RequestInfoResolver) request.RequestInfoResolver {
+	return &nonResourceRequestInfoResolver{RequestInfoResolver: resolver, handlers: handlers}
+}
+
+type nonResourceRequestInfoResolver struct {
+	request.RequestInfoResolver
+	handlers nonResourceHandlers
+}
+
+func (r *nonResourceRequestInfoResolver) NewRequestInfo(req *http.Request) (*request.RequestInfo, error) {
+	if !r.handlers.matches(req.URL.Path) {
+		return r.RequestInfoResolver.NewRequestInfo(req)
+	}
+	return &request.RequestInfo{
+		IsResourceRequest: false,
+		Path:              req.URL.Path,
+		Verb:              strings.ToLower(req.Method),
+	}, nil
+}
+
+// withAuthorizer allows the requests to the AlwaysAllow handlers and leaves the others to the
+// authorizer a
+func (handlers nonResourceHandlers) withAuthorizer(a authorizer.Authorizer) (authorizer.Authorizer, error) {
+	paths := []string{}
+	for _, h := range handlers {
+		if !h.AlwaysAllow {
+			continue
+		}
+		if strings.HasSuffix(h.Path, "/") {
+			paths = append(paths, h.Path+"*")
+		} else {
+			paths = append(paths, h.Path)
+		}
+	}
+	if a == nil || len(paths) == 0 {
+		return a, nil
+	}
+	pathAuthorizer, err := path.NewAuthorizer(paths)
+	if err != nil {
+		return nil, err
+	}
+	return union.New(pathAuthorizer, a), nil
+}
+
+// withOpenAPI lists the paths of the handlers with an OpenAPI description in the served spec
+func (handlers nonResourceHandlers) withOpenAPI(postProcess func(*spec.Swagger) (*spec.Swagger, error)) func(*spec.Swagger) (*spec.Swagger, error) {
+	return func(swagger *spec.Swagger) (*spec.Swagger, error) {
+		if postProcess != nil {
+			var err error
+			if swagger, err = postProcess(swagger); err != nil {
+				return nil, err
+			}
+		}
+		for _, h := range handlers {
+			if h.OpenAPI == nil {
+				continue
+			}
+			if swagger.Paths == nil {
+				swagger.Paths = &spec.Paths{}
+			}
+			if swagger.Paths.Paths == nil {
+				swagger.Paths.Paths = map[string]spec.PathItem{}
+			}
+			swagger.Paths.Paths[h.Path] = *h.OpenAPI
+		}
+		return swagger, nil
+	}
+}
+
+// withNonResourceHandlers serves the requests to the handlers from the NonGoRestfulMux ahead of
+// the go-restful container, which claims every path below the path of a group version
+func (handlers nonResourceHandlers) withNonResourceHandlers(handler http.Handler, mux func() http.Handler) http.Handler {
+	if len(handlers) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if handlers.matches(req.URL.Path) {
+			mux().ServeHTTP(w, req)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
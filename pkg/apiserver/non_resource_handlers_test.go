@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/version"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+)
+
+func newGenericServer(t *testing.T) *genericapiserver.GenericAPIServer {
+	config := genericapiserver.NewConfig(builders.Codecs)
+	config.LoopbackClientConfig = &rest.Config{}
+	config.ExternalAddress = "localhost:443"
+	config.Version = &version.Info{}
+	server, err := config.Complete(nil).New("test", genericapiserver.NewEmptyDelegate())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return server
+}
+
+func TestNonResourceHandlersValidatePaths(t *testing.T) {
+	tests := []struct {
+		path    string
+		openAPI bool
+		wantErr bool
+	}{
+		{path: "/apis/widgets.example.com/v1/-/webhooks"},
+		{path: "/debug/widgets/"},
+		// The OpenAPI spec is only served with an OpenAPI config
+		{path: "/openapi/v2"},
+		{path: "/openapi/v2", openAPI: true, wantErr: true},
+		{path: "/healthz", wantErr: true},
+		{path: "/healthz/widgets", wantErr: true},
+		{path: "/livez", wantErr: true},
+		{path: "/readyz/", wantErr: true},
+		{path: "/metrics", wantErr: true},
+		{path: "/version", wantErr: true},
+		{path: "/apis", wantErr: true},
+	}
+	server := newGenericServer(t)
+	for _, tt := range tests {
+		handlers := nonResourceHandlers{{Path: tt.path, Handler: http.NotFoundHandler()}}
+		err := handlers.validatePaths(server, tt.openAPI)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "is served by the server") {
+				t.Errorf("validatePaths() of %s (openAPI: %v) = %v, want an error", tt.path, tt.openAPI, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("validatePaths() of %s (openAPI: %v) failed: %v", tt.path, tt.openAPI, err)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/rest"
 	genericapiserver "k8s.io/apiserver/pkg/server"
+	serverstorage "k8s.io/apiserver/pkg/server/storage"
 )
 
 // Global registry of API groups
@@ -76,12 +77,13 @@ func (g *APIGroupBuilder) GetLegacyCodec() []schema.GroupVersion {
 }
 
 func (g *APIGroupBuilder) registerEndpoints(
-	optionsGetter generic.RESTOptionsGetter,
-	registry map[string]map[string]rest.Storage) {
+	ctx *RESTContext,
+	registry map[string]map[string]rest.Storage,
+	resourceConfig *serverstorage.ResourceConfig) {
 
 	// Register the endpoints for each version
 	for _, v := range g.Versions {
-		v.registerEndpoints(optionsGetter, registry)
+		v.registerEndpoints(ctx, registry, resourceConfig)
 	}
 }
 
@@ -95,6 +97,13 @@ func (g *APIGroupBuilder) registerVersionPriorities(scheme *runtime.Scheme) erro
 
 // Build returns a new NewDefaultAPIGroupInfo to install into a GenericApiServer
 func (g *APIGroupBuilder) Build(optionsGetter generic.RESTOptionsGetter) *genericapiserver.APIGroupInfo {
+	return g.BuildWithResourceConfig(&RESTContext{RESTOptionsGetter: optionsGetter}, nil)
+}
+
+// BuildWithResourceConfig returns a new NewDefaultAPIGroupInfo serving the group versions and
+// resources enabled by resourceConfig, e.g. with --runtime-config. The REST of the resources
+// are built from ctx.
+func (g *APIGroupBuilder) BuildWithResourceConfig(ctx *RESTContext, resourceConfig *serverstorage.ResourceConfig) *genericapiserver.APIGroupInfo {
 
 	// Build a new group
 	i := genericapiserver.NewDefaultAPIGroupInfo(
@@ -103,10 +112,17 @@ func (g *APIGroupBuilder) Build(optionsGetter generic.RESTOptionsGetter) *generi
 		ParameterCodec,
 		Codecs)
 
-	g.registerEndpoints(optionsGetter, i.VersionedResourcesStorageMap)
+	g.registerEndpoints(ctx, i.VersionedResourcesStorageMap, resourceConfig)
 
+	// The preferred version of the group is the first version enabled
+	enabled := []schema.GroupVersion{}
+	for _, version := range i.PrioritizedVersions {
+		if len(i.VersionedResourcesStorageMap[version.Version]) > 0 {
+			enabled = append(enabled, version)
+		}
+	}
+	i.PrioritizedVersions = enabled
 	return &i
-
 }
 
 // Deprecated
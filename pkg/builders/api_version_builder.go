@@ -21,8 +21,8 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/rest"
+	serverstorage "k8s.io/apiserver/pkg/server/storage"
 )
 
 type VersionedApiBuilder struct {
@@ -33,6 +33,14 @@ type VersionedApiBuilder struct {
 	// Deprecated: Only for compiliation backward-compatibility w/ 1.12+ version generators
 	// removing in the future
 	SchemaBuilder runtime.SchemeBuilder
+
+	// OpenAPIDefaults are the json values of the +default comment tags by OpenAPI definition
+	// name and property name, e.g. the defaults of the properties of a spec
+	OpenAPIDefaults map[string]map[string]string
+
+	// OpenAPIValidationRules are the +validation:rule comment tags by OpenAPI definition name and
+	// property name, the rules of a definition itself have an empty property name
+	OpenAPIValidationRules map[string]map[string][]ValidationRule
 }
 
 func NewApiVersion(group, version string) *VersionedApiBuilder {
@@ -49,22 +57,65 @@ func (s *VersionedApiBuilder) WithResources(resourceBuilders ...*versionedResour
 	return s
 }
 
+// WithOpenAPIDefaults adds the defaults of the properties of OpenAPI definitions of the API version
+// defaults is keyed by definition name, then by property name, the values are json
+func (s *VersionedApiBuilder) WithOpenAPIDefaults(defaults map[string]map[string]string) *VersionedApiBuilder {
+	if s.OpenAPIDefaults == nil {
+		s.OpenAPIDefaults = map[string]map[string]string{}
+	}
+	for definition, properties := range defaults {
+		if s.OpenAPIDefaults[definition] == nil {
+			s.OpenAPIDefaults[definition] = map[string]string{}
+		}
+		for property, value := range properties {
+			s.OpenAPIDefaults[definition][property] = value
+		}
+	}
+	return s
+}
+
+// WithOpenAPIValidationRules adds the rules of OpenAPI definitions and their properties of the
+// API version, rules is keyed by definition name, then by property name
+func (s *VersionedApiBuilder) WithOpenAPIValidationRules(rules map[string]map[string][]ValidationRule) *VersionedApiBuilder {
+	if s.OpenAPIValidationRules == nil {
+		s.OpenAPIValidationRules = map[string]map[string][]ValidationRule{}
+	}
+	for definition, properties := range rules {
+		if s.OpenAPIValidationRules[definition] == nil {
+			s.OpenAPIValidationRules[definition] = map[string][]ValidationRule{}
+		}
+		for property, r := range properties {
+			s.OpenAPIValidationRules[definition][property] = append(s.OpenAPIValidationRules[definition][property], r...)
+		}
+	}
+	return s
+}
+
 // registerEndpoints registers the REST endpoints for all resources in this API group version
 // group is the group to register the resources under
-// optionsGetter is the RESTOptionsGetter provided by a server.Config
+// ctx carries the RESTOptionsGetter and the clients provided by the server
 // registry is the server.APIGroupInfo VersionedResourcesStorageMap used to register REST endpoints
 func (s *VersionedApiBuilder) registerEndpoints(
-	optionsGetter generic.RESTOptionsGetter,
-	registry map[string]map[string]rest.Storage) {
+	ctx *RESTContext,
+	registry map[string]map[string]rest.Storage,
+	resourceConfig *serverstorage.ResourceConfig) {
+
+	if resourceConfig != nil && !resourceConfig.VersionEnabled(s.GroupVersion) {
+		return
+	}
 
 	// Register the endpoints for each kind
 	for _, k := range s.Kinds {
+		// The subresources are disabled with their resource
+		if resourceConfig != nil && !resourceConfig.ResourceEnabled(s.GroupVersion.WithResource(k.Unversioned.GetName())) {
+			continue
+		}
 		if _, found := registry[s.GroupVersion.Version]; !found {
 			// Initialize the version if missing
 			registry[s.GroupVersion.Version] = map[string]rest.Storage{}
 		}
 		// Register each of the endpoints in this version
-		k.registerEndpoints(s.GroupVersion.Group, optionsGetter, registry[s.GroupVersion.Version])
+		k.registerEndpoints(s.GroupVersion.Group, ctx, registry[s.GroupVersion.Version])
 	}
 }
 
@@ -50,7 +50,10 @@ func NewApiResource(
 	}
 
 	return &versionedResourceBuilder{
-		unversionedBuilder, new, newList, storeBuilder, nil, nil,
+		Unversioned:    unversionedBuilder,
+		NewFunc:        new,
+		NewListFunc:    newList,
+		StorageBuilder: storeBuilder,
 	}
 }
 
@@ -64,7 +67,10 @@ func NewApiResourceWithStorage(
 	new, newList func() runtime.Object,
 	RESTFunc NewRESTFunc) *versionedResourceBuilder {
 	v := &versionedResourceBuilder{
-		unversionedBuilder, new, newList, nil, RESTFunc, nil,
+		Unversioned: unversionedBuilder,
+		NewFunc:     new,
+		NewListFunc: newList,
+		RESTFunc:    RESTFunc,
 	}
 	if new == nil {
 		panic(fmt.Errorf("Cannot call NewApiResourceWithStorage with nil new function."))
@@ -75,6 +81,31 @@ func NewApiResourceWithStorage(
 	return v
 }
 
+// NewApiResourceWithRESTContext returns a new versionedResourceBuilder for registering endpoints
+// that does not require standard storage, like NewApiResourceWithStorage, and whose REST uses the
+// clients of the server, e.g. to reach the core cluster.
+// strategy - unversionedBuilder from calling NewUnversionedXXX()
+// new - function for creating new empty VERSIONED instances - e.g. func() runtime.Object { return &Deployment{} }
+// storage - storage for manipulating the resource
+func NewApiResourceWithRESTContext(
+	unversionedBuilder UnversionedResourceBuilder,
+	new, newList func() runtime.Object,
+	RESTFunc NewRESTWithContextFunc) *versionedResourceBuilder {
+	v := &versionedResourceBuilder{
+		Unversioned:         unversionedBuilder,
+		NewFunc:             new,
+		NewListFunc:         newList,
+		RESTWithContextFunc: RESTFunc,
+	}
+	if new == nil {
+		panic(fmt.Errorf("Cannot call NewApiResourceWithRESTContext with nil new function."))
+	}
+	if RESTFunc == nil {
+		panic(fmt.Errorf("Cannot call NewApiResourceWithRESTContext with nil RESTFunc function."))
+	}
+	return v
+}
+
 type versionedResourceBuilder struct {
 	Unversioned UnversionedResourceBuilder
 
@@ -90,9 +121,46 @@ type versionedResourceBuilder struct {
 	// RESTFunc returns a rest.Storage implementation, mutually exclusive with StorageBuilder
 	RESTFunc NewRESTFunc
 
+	// RESTWithContextFunc returns a rest.Storage implementation from the clients of the server,
+	// mutually exclusive with StorageBuilder and RESTFunc
+	RESTWithContextFunc NewRESTWithContextFunc
+
+	// StorageBackend is the name of the registered storage backend persisting the resource,
+	// empty for the default storage
+	StorageBackend string
+
+	// TableConvertor prints the resource for kubectl get, optional
+	TableConvertor rest.TableConvertor
+
+	// GracePeriodSeconds is the default grace period of the graceful deletion of the resource,
+	// nil if the resource is deleted immediately
+	GracePeriodSeconds *int64
+
 	Storage rest.StandardStorage
 }
 
+// WithStorageBackend persists the resource through the storage backend registered under name
+// instead of the default storage.
+func (b *versionedResourceBuilder) WithStorageBackend(name string) *versionedResourceBuilder {
+	b.StorageBackend = name
+	return b
+}
+
+// WithTableConvertor prints the resource with the table convertor instead of the default name
+// and creation timestamp columns.
+func (b *versionedResourceBuilder) WithTableConvertor(convertor rest.TableConvertor) *versionedResourceBuilder {
+	b.TableConvertor = convertor
+	return b
+}
+
+// WithGracefulDeletion deletes the resource gracefully, the objects are only marked with a
+// deletion timestamp and are removed once deleted again with a zero grace period.
+// gracePeriodSeconds is the grace period of the deletions that don't set one.
+func (b *versionedResourceBuilder) WithGracefulDeletion(gracePeriodSeconds int64) *versionedResourceBuilder {
+	b.GracePeriodSeconds = &gracePeriodSeconds
+	return b
+}
+
 func (b *versionedResourceBuilder) New() runtime.Object {
 	if b.NewFunc == nil {
 		return nil
@@ -109,22 +177,107 @@ func (b *versionedResourceBuilder) NewList() runtime.Object {
 
 type StorageWrapper struct {
 	registry.Store
+
+	// BeginCreate is an optional hook running once a created object is validated, before it is
+	// written to the storage
+	BeginCreate BeginCreateFunc
+	// BeginUpdate is an optional hook running once an updated object is validated, before it is
+	// written to the storage
+	BeginUpdate BeginUpdateFunc
 }
 
+// Create runs the BeginCreate hook once the created object is validated.
 func (s StorageWrapper) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
-	return s.Store.Create(ctx, obj, createValidation, options)
+	if s.BeginCreate == nil {
+		return s.Store.Create(ctx, obj, createValidation, options)
+	}
+
+	hooks := &beginHooks{}
+	out, err := s.Store.Create(ctx, obj, hooks.beforeCreate(createValidation, s.BeginCreate, options), options)
+	hooks.finish(ctx, err == nil)
+	return out, err
+}
+
+// Update runs the BeginUpdate hook once the updated object is validated, or the BeginCreate hook
+// if the object is created. The hooks run again if the update conflicts with another writer and
+// is retried.
+func (s StorageWrapper) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+
+	hooks := &beginHooks{}
+	if s.BeginCreate != nil {
+		createOptions := &metav1.CreateOptions{DryRun: options.DryRun, FieldManager: options.FieldManager}
+		createValidation = hooks.beforeCreate(createValidation, s.BeginCreate, createOptions)
+	}
+	if s.BeginUpdate != nil {
+		updateValidation = hooks.beforeUpdate(updateValidation, s.BeginUpdate, options)
+	}
+	obj, created, err := s.Store.Update(ctx, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+	hooks.finish(ctx, err == nil)
+	return obj, created, err
+}
+
+// beginHooks runs the Begin hooks of a storage operation after its validation
+type beginHooks struct {
+	// finishFunc is returned by the hook of the last attempt of the operation
+	finishFunc FinishFunc
+}
+
+func (h *beginHooks) beforeCreate(validate rest.ValidateObjectFunc, begin BeginCreateFunc, options *metav1.CreateOptions) rest.ValidateObjectFunc {
+	return func(ctx context.Context, obj runtime.Object) error {
+		if validate != nil {
+			if err := validate(ctx, obj); err != nil {
+				return err
+			}
+		}
+		return h.begin(ctx, func() (FinishFunc, error) {
+			return begin(ctx, obj, options)
+		})
+	}
+}
+
+func (h *beginHooks) beforeUpdate(validate rest.ValidateObjectUpdateFunc, begin BeginUpdateFunc, options *metav1.UpdateOptions) rest.ValidateObjectUpdateFunc {
+	return func(ctx context.Context, obj, old runtime.Object) error {
+		if validate != nil {
+			if err := validate(ctx, obj, old); err != nil {
+				return err
+			}
+		}
+		return h.begin(ctx, func() (FinishFunc, error) {
+			return begin(ctx, obj, old, options)
+		})
+	}
+}
+
+func (h *beginHooks) begin(ctx context.Context, hook func() (FinishFunc, error)) error {
+	// The previous attempt conflicted with another writer
+	h.finish(ctx, false)
+	var err error
+	h.finishFunc, err = hook()
+	return err
+}
+
+func (h *beginHooks) finish(ctx context.Context, success bool) {
+	if h.finishFunc != nil {
+		h.finishFunc(ctx, success)
+		h.finishFunc = nil
+	}
 }
 
 func (b *versionedResourceBuilder) Build(
 	group string,
 	optionsGetter generic.RESTOptionsGetter) rest.StandardStorage {
 
+	optionsGetter = b.getRESTOptionsGetter(group, optionsGetter)
+
 	// Set a default strategy
 	store := &StorageWrapper{
-		registry.Store{
+		Store: registry.Store{
 			NewFunc:                  b.Unversioned.New,     // Use the unversioned type
 			NewListFunc:              b.Unversioned.NewList, // Use the unversioned type
 			DefaultQualifiedResource: b.getGroupResource(group),
+			TableConvertor:           b.TableConvertor,
 		},
 	}
 	b.Storage = store
@@ -149,6 +302,16 @@ func (b *versionedResourceBuilder) Build(
 		b.StorageBuilder.Build(b.StorageBuilder, storeWithShortcuts.StorageWrapper, options)
 	}
 
+	if b.GracePeriodSeconds != nil && store.DeleteStrategy != nil {
+		// Strategies implementing rest.RESTGracefulDeleteStrategy keep their own grace period
+		if _, graceful := store.DeleteStrategy.(rest.RESTGracefulDeleteStrategy); !graceful {
+			store.DeleteStrategy = &gracefulDeleteStrategy{
+				RESTDeleteStrategy: store.DeleteStrategy,
+				gracePeriodSeconds: *b.GracePeriodSeconds,
+			}
+		}
+	}
+
 	if err := storeWithShortcuts.CompleteWithOptions(options); err != nil {
 		panic(err) // TODO: Propagate error up
 	}
@@ -169,13 +332,29 @@ func (b *versionedResourceBuilder) getGroupResource(group string) schema.GroupRe
 
 }
 
+// getRESTOptionsGetter returns the RESTOptionsGetter of the storage backend selected for this
+// Resource, or optionsGetter if the Resource uses the default storage
+func (b *versionedResourceBuilder) getRESTOptionsGetter(
+	group string,
+	optionsGetter generic.RESTOptionsGetter) generic.RESTOptionsGetter {
+
+	backend, err := getStorageBackend(b.getGroupResource(group), b.StorageBackend)
+	if err != nil {
+		panic(err) // TODO: Propagate error up
+	}
+	if backend == nil {
+		return optionsGetter
+	}
+	return backend(optionsGetter)
+}
+
 // registerEndpoints registers the REST endpoints for this resource in the registry
 // group is the group to register the resource under
-// optionsGetter is the RESTOptionsGetter provided by a server.Config
+// ctx carries the RESTOptionsGetter and the clients provided by the server
 // registry is the server.APIGroupInfo VersionedResourcesStorageMap used to register REST endpoints
 func (b *versionedResourceBuilder) registerEndpoints(
 	group string,
-	optionsGetter generic.RESTOptionsGetter,
+	ctx *RESTContext,
 	registry map[string]rest.Storage) {
 
 	// Register the endpoint
@@ -187,12 +366,16 @@ func (b *versionedResourceBuilder) registerEndpoints(
 		path = b.Unversioned.GetName()
 	}
 
-	if b.RESTFunc != nil {
+	if b.RESTWithContextFunc != nil {
+		// Use the REST implementation directly, with the clients of the server.
+		registry[path] = b.RESTWithContextFunc(
+			ctx.withRESTOptionsGetter(b.getRESTOptionsGetter(group, ctx.RESTOptionsGetter)))
+	} else if b.RESTFunc != nil {
 		// Use the REST implementation directly.
-		registry[path] = b.RESTFunc(optionsGetter)
+		registry[path] = b.RESTFunc(b.getRESTOptionsGetter(group, ctx.RESTOptionsGetter))
 	} else {
 		// Create a new REST implementation wired to storage.
 		registry[path] = b.
-			Build(group, optionsGetter)
+			Build(group, ctx.RESTOptionsGetter)
 	}
 }
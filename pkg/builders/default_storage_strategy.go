@@ -21,14 +21,18 @@ import (
 	"fmt"
 	"reflect"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/apiserver/pkg/storage"
 	"k8s.io/apiserver/pkg/storage/names"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/validators"
 )
 
 var _ rest.RESTCreateStrategy = &DefaultStorageStrategy{}
@@ -65,8 +69,26 @@ func (DefaultStorageStrategy) Build(builder StorageBuilder, store *StorageWrappe
 	store.UpdateStrategy = builder
 	store.DeleteStrategy = builder
 
+	store.AfterCreate = builder.AfterCreate
+	store.AfterUpdate = builder.AfterUpdate
+	store.AfterDelete = builder.AfterDelete
+	store.Decorator = builder.Decorate
+	store.BeginCreate = builder.BeginCreate
+	store.BeginUpdate = builder.BeginUpdate
+
 	options.AttrFunc = builder.GetAttrs
-	options.TriggerFunc = builder.GetTriggerFuncs()
+
+	// Strategies may declare their own trigger functions, otherwise the fields declared with
+	// +selectablefield:...,index=true are indexed
+	triggerFuncs := builder.GetTriggerFuncs()
+	if len(triggerFuncs) == 0 {
+		triggerFuncs = indexedFieldTriggerFuncs(builder, store.NewFunc())
+	}
+	if len(triggerFuncs) > 0 {
+		options.TriggerFunc = triggerFuncs
+		options.Indexers = fieldIndexers(triggerFuncs)
+		store.PredicateFunc = indexedPredicateFunc(builder.BasicMatch, triggerFuncs)
+	}
 }
 
 func (DefaultStorageStrategy) NamespaceScoped() bool { return true }
@@ -103,14 +125,91 @@ func (DefaultStorageStrategy) PrepareForUpdate(ctx context.Context, obj, old run
 	}
 }
 
+// Validate validates the object against its OpenAPI schema and the +validation comment tags of
+// its fields. Strategies overriding Validate should call it and append their own errors to keep
+// the generated validation.
 func (DefaultStorageStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
-	return field.ErrorList{}
+	errs := validateSchema(ctx, obj)
+	return append(errs, validateFields(obj)...)
 }
 
+// ValidateUpdate validates the updated object against its OpenAPI schema and the +validation
+// comment tags of its fields, and the changes to the fields against their +immutable comment tags.
 func (DefaultStorageStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	errs := validateSchema(ctx, obj)
+	errs = append(errs, validateFields(obj)...)
+	return append(errs, validateFieldUpdates(obj, old)...)
+}
+
+// AfterCreate does nothing by default. Strategies may override it to run side effects once the
+// objects are created.
+func (DefaultStorageStrategy) AfterCreate(obj runtime.Object) error {
+	return nil
+}
+
+// AfterUpdate does nothing by default. Strategies may override it to run side effects once the
+// objects are updated.
+func (DefaultStorageStrategy) AfterUpdate(obj runtime.Object) error {
+	return nil
+}
+
+// AfterDelete does nothing by default. Strategies may override it to clean up after the
+// objects are deleted.
+func (DefaultStorageStrategy) AfterDelete(obj runtime.Object) error {
+	return nil
+}
+
+// Decorate does nothing by default. Strategies may override it to set the computed fields of the
+// objects returned from the storage. obj is either an object or a list of objects.
+func (DefaultStorageStrategy) Decorate(obj runtime.Object) error {
+	return nil
+}
+
+// BeginCreate does nothing by default. Strategies may override it to start an operation that must
+// be committed or rolled back by the returned FinishFunc depending on the creation outcome.
+func (DefaultStorageStrategy) BeginCreate(ctx context.Context, obj runtime.Object, options *metav1.CreateOptions) (FinishFunc, error) {
+	return func(context.Context, bool) {}, nil
+}
+
+// BeginUpdate does nothing by default. Strategies may override it to start an operation that must
+// be committed or rolled back by the returned FinishFunc depending on the update outcome.
+func (DefaultStorageStrategy) BeginUpdate(ctx context.Context, obj, old runtime.Object, options *metav1.UpdateOptions) (FinishFunc, error) {
+	return func(context.Context, bool) {}, nil
+}
+
+func validateFields(obj runtime.Object) field.ErrorList {
+	if v, ok := obj.(HasFieldValidation); ok {
+		return v.ValidateFields()
+	}
 	return field.ErrorList{}
 }
 
+func validateFieldUpdates(obj, old runtime.Object) field.ErrorList {
+	if v, ok := obj.(HasFieldUpdateValidation); ok {
+		return v.ValidateFieldUpdates(old)
+	}
+	return field.ErrorList{}
+}
+
+// validateSchema validates the object against the OpenAPI schema of the version it was
+// requested in.
+func validateSchema(ctx context.Context, obj runtime.Object) field.ErrorList {
+	info, found := request.RequestInfoFrom(ctx)
+	if !found || !info.IsResourceRequest {
+		return field.ErrorList{}
+	}
+	kinds, _, err := Scheme.ObjectKinds(obj)
+	if err != nil {
+		return field.ErrorList{}
+	}
+	gv := schema.GroupVersion{Group: info.APIGroup, Version: info.APIVersion}
+	versioned, err := Scheme.ConvertToVersion(obj, gv)
+	if err != nil {
+		return field.ErrorList{field.InternalError(nil, err)}
+	}
+	return validators.OpenAPI.ValidateObject(gv.WithKind(kinds[0].Kind), versioned)
+}
+
 func (b DefaultStorageStrategy) GetAttrs(obj runtime.Object) (labels.Set, fields.Set, error) {
 	switch t := obj.(type) {
 	case HasObjectMeta:
@@ -124,13 +223,21 @@ func (b DefaultStorageStrategy) GetAttrs(obj runtime.Object) (labels.Set, fields
 	}
 }
 
+// GetTriggerFuncs returns the functions computing the values of the indexed fields of the
+// objects, keyed by the field names. Strategies may override it to declare the fields the
+// watch cache indexes, the watch cache supports a single trigger function per resource.
 func (b DefaultStorageStrategy) GetTriggerFuncs() storage.IndexerFuncs {
 	return nil
 }
 
-// GetSelectableFields returns a field set that represents the object.
+// GetSelectableFields returns a field set that represents the object, its metadata name and
+// namespace followed by the fields declared with +selectablefield.
 func (DefaultStorageStrategy) GetSelectableFields(obj HasObjectMeta) fields.Set {
-	return generic.ObjectMetaFieldsSet(obj.GetObjectMeta(), true)
+	set := generic.ObjectMetaFieldsSet(obj.GetObjectMeta(), true)
+	if s, ok := obj.(HasSelectableFields); ok {
+		set = generic.MergeFieldsSets(set, s.GetSelectableFields())
+	}
+	return set
 }
 
 // MatchResource is the filter used by the generic etcd backend to watch events
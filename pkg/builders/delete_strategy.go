@@ -0,0 +1,85 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+var _ rest.RESTGracefulDeleteStrategy = &gracefulDeleteStrategy{}
+
+// gracefulDeleteStrategy deletes objects gracefully. The objects are marked with a deletion
+// timestamp and the grace period, and are kept until they are deleted again with a grace period
+// of 0, e.g. by the controller once it cleaned up after them. Nothing removes them when the grace
+// period expires.
+type gracefulDeleteStrategy struct {
+	rest.RESTDeleteStrategy
+
+	gracePeriodSeconds int64
+}
+
+// CheckGracefulDelete implements rest.RESTGracefulDeleteStrategy. The grace period of the
+// resource applies unless the request sets one.
+func (s *gracefulDeleteStrategy) CheckGracefulDelete(ctx context.Context, obj runtime.Object, options *metav1.DeleteOptions) bool {
+	if options == nil {
+		return false
+	}
+	if options.GracePeriodSeconds == nil {
+		period := s.gracePeriodSeconds
+		options.GracePeriodSeconds = &period
+	}
+	return true
+}
+
+// HasFinalizer returns true if the object has the finalizer
+func HasFinalizer(obj metav1.Object, finalizer string) bool {
+	for _, f := range obj.GetFinalizers() {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFinalizer adds the finalizer to the object and returns true if it was missing
+func AddFinalizer(obj metav1.Object, finalizer string) bool {
+	if HasFinalizer(obj, finalizer) {
+		return false
+	}
+	obj.SetFinalizers(append(obj.GetFinalizers(), finalizer))
+	return true
+}
+
+// RemoveFinalizer removes the finalizer from the object and returns true if it was present.
+// Objects being deleted are removed from the storage once their last finalizer is removed.
+func RemoveFinalizer(obj metav1.Object, finalizer string) bool {
+	finalizers := []string{}
+	for _, f := range obj.GetFinalizers() {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) == len(obj.GetFinalizers()) {
+		return false
+	}
+	obj.SetFinalizers(finalizers)
+	return true
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"k8s.io/apimachinery/pkg/util/sets"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/healthz"
+)
+
+// ReadyzChecker is implemented by the REST of resources served through other services, e.g. the
+// kubelets for a pods/log REST. The server isn't ready until the checks pass.
+type ReadyzChecker interface {
+	ReadyzChecks() []healthz.HealthChecker
+}
+
+// GetReadyzChecks returns the checks of the storage of the group implementing ReadyzChecker. The
+// checks are added once by name, e.g. for the storage of every version of a resource.
+func GetReadyzChecks(group *genericapiserver.APIGroupInfo) []healthz.HealthChecker {
+	checks := []healthz.HealthChecker{}
+	names := sets.NewString()
+	for _, version := range group.PrioritizedVersions {
+		storage := group.VersionedResourcesStorageMap[version.Version]
+		for _, resource := range sets.StringKeySet(storage).List() {
+			checker, ok := storage[resource].(ReadyzChecker)
+			if !ok {
+				continue
+			}
+			for _, check := range checker.ReadyzChecks() {
+				if names.Has(check.Name()) {
+					continue
+				}
+				names.Insert(check.Name())
+				checks = append(checks, check)
+			}
+		}
+	}
+	return checks
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/kube-openapi/pkg/common"
+)
+
+// WithOpenAPIDefaults returns the OpenAPI definitions of getDefinitions with the defaults of the
+// API versions of groups set on their properties, so the served schema documents the defaults
+// applied by the generated SetDefaults functions
+func WithOpenAPIDefaults(getDefinitions common.GetOpenAPIDefinitions, groups []*APIGroupBuilder) common.GetOpenAPIDefinitions {
+	defaults := map[string]map[string]string{}
+	for _, group := range groups {
+		for _, version := range group.Versions {
+			for definition, properties := range version.OpenAPIDefaults {
+				defaults[definition] = properties
+			}
+		}
+	}
+	if len(defaults) == 0 || getDefinitions == nil {
+		return getDefinitions
+	}
+
+	return func(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+		definitions := getDefinitions(ref)
+		for name, properties := range defaults {
+			definition, found := definitions[name]
+			if !found {
+				continue
+			}
+			for property, value := range properties {
+				schema, found := definition.Schema.Properties[property]
+				if !found {
+					continue
+				}
+				if err := json.Unmarshal([]byte(value), &schema.Default); err != nil {
+					// TODO: Propagate error up
+					panic(fmt.Errorf("invalid default %s of %s.%s: %v", value, name, property, err))
+				}
+				definition.Schema.Properties[property] = schema
+			}
+			definitions[name] = definition
+		}
+		return definitions
+	}
+}
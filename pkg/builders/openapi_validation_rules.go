@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"k8s.io/kube-openapi/pkg/common"
+)
+
+// ValidationRule is a +validation:rule comment tag, evaluated by the generated validation
+type ValidationRule struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message,omitempty"`
+}
+
+// WithOpenAPIValidationRules returns the OpenAPI definitions of getDefinitions with the validation
+// rules of the API versions of groups listed in the descriptions of the definitions and their
+// properties, and in their x-kubernetes-validations extension, so the served schema and the
+// reference docs generated from it document the rules
+func WithOpenAPIValidationRules(getDefinitions common.GetOpenAPIDefinitions, groups []*APIGroupBuilder) common.GetOpenAPIDefinitions {
+	rules := map[string]map[string][]ValidationRule{}
+	for _, group := range groups {
+		for _, version := range group.Versions {
+			for definition, properties := range version.OpenAPIValidationRules {
+				rules[definition] = properties
+			}
+		}
+	}
+	if len(rules) == 0 || getDefinitions == nil {
+		return getDefinitions
+	}
+
+	return func(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+		definitions := getDefinitions(ref)
+		for name, properties := range rules {
+			definition, found := definitions[name]
+			if !found {
+				continue
+			}
+			for property, r := range properties {
+				if len(property) == 0 {
+					addValidationRules(&definition.Schema, r)
+					continue
+				}
+				schema, found := definition.Schema.Properties[property]
+				if !found {
+					continue
+				}
+				addValidationRules(&schema, r)
+				definition.Schema.Properties[property] = schema
+			}
+			definitions[name] = definition
+		}
+		return definitions
+	}
+}
+
+func addValidationRules(schema *spec.Schema, rules []ValidationRule) {
+	lines := []string{"Validation rules:"}
+	for _, r := range rules {
+		if len(r.Message) > 0 {
+			lines = append(lines, fmt.Sprintf("- %s: %s", r.Rule, r.Message))
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s", r.Rule))
+		}
+	}
+	if len(schema.Description) > 0 {
+		schema.Description += "\n\n"
+	}
+	schema.Description += strings.Join(lines, "\n")
+	schema.AddExtension("x-kubernetes-validations", rules)
+}
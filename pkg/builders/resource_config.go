@@ -0,0 +1,83 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/server/resourceconfig"
+	serverstorage "k8s.io/apiserver/pkg/server/storage"
+)
+
+// NewAPIResourceConfig returns the group versions and resources of the apis enabled by the
+// runtimeConfig of --runtime-config. Every group version is enabled by default, the keys are
+// either api/all, api/ga, api/beta, api/alpha, <group>/<version> or <group>/<version>/<resource>.
+func NewAPIResourceConfig(apis []*APIGroupBuilder, runtimeConfig map[string]string) (*serverstorage.ResourceConfig, error) {
+	config := serverstorage.NewResourceConfig()
+	served := map[schema.GroupVersion]sets.String{}
+	for _, api := range apis {
+		for _, version := range api.Versions {
+			config.EnableVersions(version.GroupVersion)
+			resources := sets.NewString()
+			for _, kind := range version.Kinds {
+				resources.Insert(kind.Unversioned.GetName())
+			}
+			served[version.GroupVersion] = resources
+		}
+	}
+
+	// The generic apiserver only supports group versions, the resources are enabled on top of them
+	versions := map[string]string{}
+	resources := map[schema.GroupVersionResource]bool{}
+	for key, value := range runtimeConfig {
+		tokens := strings.Split(key, "/")
+		if len(tokens) != 3 {
+			versions[key] = value
+			continue
+		}
+		gvr := schema.GroupVersionResource{Group: tokens[0], Version: tokens[1], Resource: tokens[2]}
+		if !served[gvr.GroupVersion()].Has(gvr.Resource) {
+			return nil, fmt.Errorf("invalid runtime-config key %s, the resource isn't served", key)
+		}
+		enabled := true
+		if len(value) > 0 {
+			var err error
+			if enabled, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid value of %s: %s, err: %v", key, value, err)
+			}
+		}
+		resources[gvr] = enabled
+	}
+
+	config, err := resourceconfig.MergeAPIResourceConfigs(config, versions, Scheme)
+	if err != nil {
+		return nil, err
+	}
+	for gvr, enabled := range resources {
+		if enabled {
+			config.EnableVersions(gvr.GroupVersion())
+			config.EnableResources(gvr)
+		} else {
+			config.DisableResources(gvr)
+		}
+	}
+	return config, nil
+}
@@ -51,6 +51,30 @@ type HasObjectMeta interface {
 	GetObjectMeta() *metav1.ObjectMeta
 }
 
+// HasFieldValidation is implemented by resources whose fields have +validation comment tags.
+// The method is generated by apiregister-gen.
+type HasFieldValidation interface {
+	ValidateFields() field.ErrorList
+}
+
+// HasFieldUpdateValidation is implemented by resources whose fields have +immutable comment tags.
+// The method is generated by apiregister-gen.
+type HasFieldUpdateValidation interface {
+	ValidateFieldUpdates(old runtime.Object) field.ErrorList
+}
+
+// HasSelectableFields is implemented by resources with +selectablefield comment tags.
+// The method is generated by apiregister-gen.
+type HasSelectableFields interface {
+	GetSelectableFields() fields.Set
+}
+
+// HasIndexedFields is implemented by resources with +selectablefield comment tags indexed by the
+// watch cache. The method is generated by apiregister-gen.
+type HasIndexedFields interface {
+	GetIndexedFields() []string
+}
+
 type StorageBuilder interface {
 	Build(builder StorageBuilder, store *StorageWrapper, options *generic.StoreOptions)
 
@@ -70,8 +94,32 @@ type StorageBuilder interface {
 	GetTriggerFuncs() storage.IndexerFuncs
 	GetSelectableFields(obj HasObjectMeta) fields.Set
 	BasicMatch(label labels.Selector, field fields.Selector) storage.SelectionPredicate
+
+	// AfterCreate runs after an object is created in the storage
+	AfterCreate(obj runtime.Object) error
+	// AfterUpdate runs after an object is updated in the storage
+	AfterUpdate(obj runtime.Object) error
+	// AfterDelete runs after an object is deleted from the storage
+	AfterDelete(obj runtime.Object) error
+	// Decorate runs on the objects and lists returned from the storage, e.g. to set computed fields
+	Decorate(obj runtime.Object) error
+	// BeginCreate runs once a created object is validated, before it is written to the storage.
+	// The returned FinishFunc runs once the creation succeeded or failed.
+	BeginCreate(ctx context.Context, obj runtime.Object, options *metav1.CreateOptions) (FinishFunc, error)
+	// BeginUpdate runs once an updated object is validated, before it is written to the storage.
+	// The returned FinishFunc runs once the update succeeded or failed.
+	BeginUpdate(ctx context.Context, obj, old runtime.Object, options *metav1.UpdateOptions) (FinishFunc, error)
 }
 
+// FinishFunc runs once the storage operation started by a Begin hook succeeded or failed
+type FinishFunc func(ctx context.Context, success bool)
+
+// BeginCreateFunc runs once a created object is validated, before it is written to the storage
+type BeginCreateFunc func(ctx context.Context, obj runtime.Object, options *metav1.CreateOptions) (FinishFunc, error)
+
+// BeginUpdateFunc runs once an updated object is validated, before it is written to the storage
+type BeginUpdateFunc func(ctx context.Context, obj, old runtime.Object, options *metav1.UpdateOptions) (FinishFunc, error)
+
 // Deprecated
 type SchemeFns interface {
 	DefaultingFunction(obj interface{})
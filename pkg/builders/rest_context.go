@@ -0,0 +1,60 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+)
+
+// RESTContext carries the clients of the server to the REST of the resources. The clients of
+// the core cluster are nil unless the server runs in-cluster or with --kubeconfig.
+type RESTContext struct {
+	// RESTOptionsGetter persists the resource, e.g. through its storage backend
+	RESTOptionsGetter generic.RESTOptionsGetter
+
+	// KubeConfig is the config of the core cluster, from --kubeconfig or the in-cluster config
+	KubeConfig *restclient.Config
+	// KubeClient is the clientset of the core cluster
+	KubeClient kubernetes.Interface
+	// KubeInformerFactory is the informer factory of the core cluster, it is started with the
+	// server
+	KubeInformerFactory informers.SharedInformerFactory
+
+	// LoopbackClientConfig is the config of the server itself, e.g. for the generated clientset
+	// of its resources
+	LoopbackClientConfig *restclient.Config
+	// AggregatedClient is the client of the resources of the server
+	AggregatedClient dynamic.Interface
+
+	// StopCh is closed once the server is stopped
+	StopCh <-chan struct{}
+}
+
+// NewRESTWithContextFunc returns a rest.Storage implementation using the clients of the server
+type NewRESTWithContextFunc func(ctx *RESTContext) rest.Storage
+
+// withRESTOptionsGetter returns a copy of the context persisting through optionsGetter
+func (c *RESTContext) withRESTOptionsGetter(optionsGetter generic.RESTOptionsGetter) *RESTContext {
+	ctx := *c
+	ctx.RESTOptionsGetter = optionsGetter
+	return &ctx
+}
@@ -0,0 +1,258 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// ScaleSubresource locates the replicas of a resource for its scale subresource. The paths are
+// json paths in the versioned resource - e.g. .spec.replicas
+type ScaleSubresource struct {
+	// SpecReplicasPath is the path of the desired replicas
+	SpecReplicasPath string
+	// StatusReplicasPath is the path of the observed replicas
+	StatusReplicasPath string
+	// LabelSelectorPath is the optional path of the label selector of the replicas, either a
+	// serialized label selector string or a metav1.LabelSelector
+	LabelSelectorPath string
+}
+
+var _ rest.Getter = &scaleREST{}
+var _ rest.Updater = &scaleREST{}
+var _ rest.GroupVersionKindProvider = &scaleREST{}
+
+// scaleREST serves the autoscaling/v1 Scale of a resource from the storage of the resource
+type scaleREST struct {
+	parent StandardStorageProvider
+	scale  ScaleSubresource
+}
+
+// NewScaleREST returns the REST implementation of the scale subresource of the resource stored in
+// parent, so that kubectl scale and the HorizontalPodAutoscaler work against it.
+func NewScaleREST(parent StandardStorageProvider, scale ScaleSubresource) rest.Storage {
+	return &scaleREST{
+		parent: parent,
+		scale:  scale,
+	}
+}
+
+// New implements rest.Storage.
+func (r *scaleREST) New() runtime.Object {
+	return &autoscalingv1.Scale{}
+}
+
+// GroupVersionKind implements rest.GroupVersionKindProvider.
+func (r *scaleREST) GroupVersionKind(containingGV schema.GroupVersion) schema.GroupVersionKind {
+	return autoscalingv1.SchemeGroupVersion.WithKind("Scale")
+}
+
+// Get implements rest.Getter.
+func (r *scaleREST) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	obj, err := r.parent.GetStandardStorage().Get(ctx, name, options)
+	if err != nil {
+		return nil, err
+	}
+	return r.toScale(ctx, obj)
+}
+
+// Update implements rest.Updater. Only the desired replicas of the resource are updated.
+func (r *scaleREST) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+
+	obj, _, err := r.parent.GetStandardStorage().Update(
+		ctx,
+		name,
+		&scaleUpdatedObjectInfo{rest: r, reqObjInfo: objInfo},
+		func(ctx context.Context, obj runtime.Object) error {
+			// The resource must exist to be scaled
+			groupResource := schema.GroupResource{}
+			if info, found := request.RequestInfoFrom(ctx); found {
+				groupResource = schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+			}
+			return apierrors.NewNotFound(groupResource, name)
+		},
+		func(ctx context.Context, obj, old runtime.Object) error {
+			scale, err := r.toScale(ctx, obj)
+			if err != nil {
+				return err
+			}
+			oldScale, err := r.toScale(ctx, old)
+			if err != nil {
+				return err
+			}
+			return updateValidation(ctx, scale, oldScale)
+		},
+		false,
+		options,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	scale, err := r.toScale(ctx, obj)
+	return scale, false, err
+}
+
+// toScale reads the Scale of the resource object
+func (r *scaleREST) toScale(ctx context.Context, obj runtime.Object) (*autoscalingv1.Scale, error) {
+	content, err := toVersionedContent(obj, requestGroupVersion(ctx))
+	if err != nil {
+		return nil, err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              accessor.GetName(),
+			Namespace:         accessor.GetNamespace(),
+			UID:               accessor.GetUID(),
+			ResourceVersion:   accessor.GetResourceVersion(),
+			CreationTimestamp: accessor.GetCreationTimestamp(),
+		},
+	}
+	specReplicas, _, err := unstructured.NestedInt64(content, jsonPathFields(r.scale.SpecReplicasPath)...)
+	if err != nil {
+		return nil, err
+	}
+	scale.Spec.Replicas = int32(specReplicas)
+	statusReplicas, _, err := unstructured.NestedInt64(content, jsonPathFields(r.scale.StatusReplicasPath)...)
+	if err != nil {
+		return nil, err
+	}
+	scale.Status.Replicas = int32(statusReplicas)
+
+	if len(r.scale.LabelSelectorPath) == 0 {
+		return scale, nil
+	}
+	selector, found, err := unstructured.NestedFieldNoCopy(content, jsonPathFields(r.scale.LabelSelectorPath)...)
+	if err != nil || !found {
+		return scale, err
+	}
+	switch s := selector.(type) {
+	case string:
+		scale.Status.Selector = s
+	case map[string]interface{}:
+		labelSelector := &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, labelSelector); err != nil {
+			return nil, err
+		}
+		parsed, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			return nil, err
+		}
+		scale.Status.Selector = parsed.String()
+	default:
+		return nil, fmt.Errorf("unsupported label selector %v at %s", selector, r.scale.LabelSelectorPath)
+	}
+	return scale, nil
+}
+
+// scaleUpdatedObjectInfo applies the updated Scale of a request to the resource object
+type scaleUpdatedObjectInfo struct {
+	rest       *scaleREST
+	reqObjInfo rest.UpdatedObjectInfo
+}
+
+// Preconditions implements rest.UpdatedObjectInfo.
+func (i *scaleUpdatedObjectInfo) Preconditions() *metav1.Preconditions {
+	return i.reqObjInfo.Preconditions()
+}
+
+// UpdatedObject implements rest.UpdatedObjectInfo.
+func (i *scaleUpdatedObjectInfo) UpdatedObject(ctx context.Context, oldObj runtime.Object) (runtime.Object, error) {
+	oldScale, err := i.rest.toScale(ctx, oldObj)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := i.reqObjInfo.UpdatedObject(ctx, oldScale)
+	if err != nil {
+		return nil, err
+	}
+	scale, ok := obj.(*autoscalingv1.Scale)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected input object type to be Scale, but %T", obj))
+	}
+	if scale.Spec.Replicas < 0 {
+		return nil, apierrors.NewInvalid(
+			autoscalingv1.SchemeGroupVersion.WithKind("Scale").GroupKind(),
+			scale.Name,
+			field.ErrorList{field.Invalid(field.NewPath("spec", "replicas"), scale.Spec.Replicas, "must be greater than or equal to 0")})
+	}
+
+	// Set the replicas on the versioned object and convert it back
+	gv := requestGroupVersion(ctx)
+	content, err := toVersionedContent(oldObj, gv)
+	if err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(content, int64(scale.Spec.Replicas), jsonPathFields(i.rest.scale.SpecReplicasPath)...); err != nil {
+		return nil, err
+	}
+	kinds, _, err := Scheme.ObjectKinds(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	versioned, err := Scheme.New(gv.WithKind(kinds[0].Kind))
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, versioned); err != nil {
+		return nil, err
+	}
+	updated, err := Scheme.ConvertToVersion(versioned, kinds[0].GroupVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	// The resourceVersion of the Scale is a precondition of the update
+	accessor, err := meta.Accessor(updated)
+	if err != nil {
+		return nil, err
+	}
+	accessor.SetResourceVersion(scale.ResourceVersion)
+	return updated, nil
+}
+
+// requestGroupVersion returns the GroupVersion of the request
+func requestGroupVersion(ctx context.Context) schema.GroupVersion {
+	if info, found := request.RequestInfoFrom(ctx); found {
+		return schema.GroupVersion{Group: info.APIGroup, Version: info.APIVersion}
+	}
+	return schema.GroupVersion{}
+}
+
+// jsonPathFields splits a simple json path into its fields - e.g. .spec.replicas
+func jsonPathFields(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
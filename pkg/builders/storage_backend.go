@@ -0,0 +1,61 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
+)
+
+// StorageBackend builds the RESTOptionsGetter used to persist a resource. The delegate is the
+// server-wide RESTOptionsGetter backed by the etcd flags, a backend usually copies the options
+// returned by the delegate and points their StorageConfig somewhere else.
+type StorageBackend func(delegate generic.RESTOptionsGetter) generic.RESTOptionsGetter
+
+var (
+	// StorageBackends is the global registry of storage backends that can be selected by name
+	// through the "+resource:storage=<name>" comment tag.
+	StorageBackends = map[string]StorageBackend{}
+
+	// ResourceStorageBackends overrides the storage backend of individual resources. An entry
+	// here takes precedence over the backend named in the resource's comment tag.
+	ResourceStorageBackends = map[schema.GroupResource]StorageBackend{}
+)
+
+// RegisterStorageBackend registers a named storage backend. Resources tagged with
+// "+resource:storage=<name>" are persisted through it.
+func RegisterStorageBackend(name string, backend StorageBackend) {
+	StorageBackends[name] = backend
+}
+
+// getStorageBackend returns the storage backend for the group resource, nil if the resource
+// uses the default storage.
+func getStorageBackend(groupResource schema.GroupResource, name string) (StorageBackend, error) {
+	if backend, found := ResourceStorageBackends[groupResource]; found {
+		return backend, nil
+	}
+	if len(name) == 0 {
+		return nil, nil
+	}
+	backend, found := StorageBackends[name]
+	if !found {
+		return nil, fmt.Errorf("storage backend %q requested by %v is not registered", name, groupResource)
+	}
+	return backend, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/client-go/tools/cache"
+)
+
+// indexedFieldTriggerFuncs returns the trigger functions of the indexed fields of obj, reading
+// the field values from the attributes of the objects
+func indexedFieldTriggerFuncs(builder StorageBuilder, obj runtime.Object) storage.IndexerFuncs {
+	indexed, ok := obj.(HasIndexedFields)
+	if !ok {
+		return nil
+	}
+	triggerFuncs := storage.IndexerFuncs{}
+	for _, name := range indexed.GetIndexedFields() {
+		name := name
+		triggerFuncs[name] = func(obj runtime.Object) string {
+			_, fieldSet, err := builder.GetAttrs(obj)
+			if err != nil {
+				return ""
+			}
+			return fieldSet[name]
+		}
+	}
+	return triggerFuncs
+}
+
+// fieldIndexers returns the watch cache indexers serving the lists filtered on the fields of the
+// trigger functions
+func fieldIndexers(triggerFuncs storage.IndexerFuncs) *cache.Indexers {
+	indexers := cache.Indexers{}
+	for name, triggerFunc := range triggerFuncs {
+		triggerFunc := triggerFunc
+		indexers[storage.FieldIndex(name)] = func(obj interface{}) ([]string, error) {
+			o, ok := obj.(runtime.Object)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object type %T", obj)
+			}
+			return []string{triggerFunc(o)}, nil
+		}
+	}
+	return &indexers
+}
+
+// indexedPredicateFunc returns the predicates of match declaring the indexed fields, so that the
+// watch cache only dispatches events to the watchers selecting their field values
+func indexedPredicateFunc(
+	match func(label labels.Selector, field fields.Selector) storage.SelectionPredicate,
+	triggerFuncs storage.IndexerFuncs) func(label labels.Selector, field fields.Selector) storage.SelectionPredicate {
+
+	indexFields := []string{}
+	for name := range triggerFuncs {
+		indexFields = append(indexFields, name)
+	}
+	sort.Strings(indexFields)
+	return func(label labels.Selector, field fields.Selector) storage.SelectionPredicate {
+		p := match(label, field)
+		p.IndexFields = append(p.IndexFields, indexFields...)
+		return p
+	}
+}
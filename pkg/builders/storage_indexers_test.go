@@ -0,0 +1,298 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders_test
+
+import (
+	"context"
+	"fmt"
+	goruntime "runtime"
+	"testing"
+	"time"
+
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage"
+	cacherstorage "k8s.io/apiserver/pkg/storage/cacher"
+	"k8s.io/apiserver/pkg/storage/etcd3"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/apiserver/pkg/storage/storagebackend/factory"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/storage/memory"
+)
+
+// watchers is the number of watchers, each selecting the objects of a different node
+const watchers = 200
+
+var benchGroupVersion = schema.GroupVersion{Group: "bench.example.com", Version: "v1"}
+
+func init() {
+	builders.Scheme.AddKnownTypes(benchGroupVersion,
+		&Machine{}, &MachineList{}, &IndexedMachine{}, &IndexedMachineList{})
+	metav1.AddToGroupVersion(builders.Scheme, benchGroupVersion)
+}
+
+// Machine is scheduled on a node, its watchers select the machines of a single node
+type Machine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MachineSpec `json:"spec,omitempty"`
+}
+
+type MachineSpec struct {
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+type MachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Machine `json:"items"`
+}
+
+func (m *Machine) DeepCopyObject() runtime.Object {
+	out := *m
+	m.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func (m *MachineList) DeepCopyObject() runtime.Object {
+	out := *m
+	out.Items = make([]Machine, len(m.Items))
+	for i := range m.Items {
+		out.Items[i] = *m.Items[i].DeepCopyObject().(*Machine)
+	}
+	return &out
+}
+
+func (m *Machine) GetObjectMeta() *metav1.ObjectMeta {
+	return &m.ObjectMeta
+}
+
+func (m *Machine) GetSelectableFields() fields.Set {
+	return fields.Set{"spec.nodeName": m.Spec.NodeName}
+}
+
+// IndexedMachine is a Machine declaring its node with +selectablefield:JSONPath=.spec.nodeName,index=true
+type IndexedMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MachineSpec `json:"spec,omitempty"`
+}
+
+type IndexedMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IndexedMachine `json:"items"`
+}
+
+func (m *IndexedMachine) DeepCopyObject() runtime.Object {
+	out := *m
+	m.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	return &out
+}
+
+func (m *IndexedMachineList) DeepCopyObject() runtime.Object {
+	out := *m
+	out.Items = make([]IndexedMachine, len(m.Items))
+	for i := range m.Items {
+		out.Items[i] = *m.Items[i].DeepCopyObject().(*IndexedMachine)
+	}
+	return &out
+}
+
+func (m *IndexedMachine) GetObjectMeta() *metav1.ObjectMeta {
+	return &m.ObjectMeta
+}
+
+// GetSelectableFields and GetIndexedFields are generated by apiregister-gen from the comment tag
+func (m *IndexedMachine) GetSelectableFields() fields.Set {
+	return fields.Set{"spec.nodeName": m.Spec.NodeName}
+}
+
+func (m *IndexedMachine) GetIndexedFields() []string {
+	return []string{"spec.nodeName"}
+}
+
+func (m *Machine) setNodeName(name string) {
+	m.Spec.NodeName = name
+}
+
+func (m *IndexedMachine) setNodeName(name string) {
+	m.Spec.NodeName = name
+}
+
+// nodeMachine is implemented by the Machine and IndexedMachine types
+type nodeMachine interface {
+	runtime.Object
+	builders.HasObjectMeta
+	setNodeName(name string)
+}
+
+// BenchmarkFilteredWatch creates machines on the nodes of the watchers and waits for the
+// watcher of their node to receive them. Without an index every watcher filters every event.
+func BenchmarkFilteredWatch(b *testing.B) {
+	unindexed := newMachineWatchers(b, "machines", "Machine", func() runtime.Object { return &Machine{} },
+		func() runtime.Object { return &MachineList{} })
+	defer unindexed.stop()
+	indexed := newMachineWatchers(b, "indexedmachines", "IndexedMachine", func() runtime.Object { return &IndexedMachine{} },
+		func() runtime.Object { return &IndexedMachineList{} })
+	defer indexed.stop()
+	// The watch cache closes the watchers blocking it once its dispatch budget is exhausted,
+	// the budget is refreshed every second
+	time.Sleep(2 * time.Second)
+
+	b.Run("unindexed", unindexed.benchmark)
+	b.Run("indexed", indexed.benchmark)
+}
+
+// machineWatchers watches the machines of every node
+type machineWatchers struct {
+	store   *builders.StorageWrapper
+	newFunc func() runtime.Object
+	nodes   []watch.Interface
+	// created is the number of machines created
+	created int
+}
+
+func newMachineWatchers(b *testing.B, resource, kind string, newFunc, newListFunc func() runtime.Object) *machineWatchers {
+	m := &machineWatchers{
+		store:   newMachineStorage(resource, kind, newFunc, newListFunc),
+		newFunc: newFunc,
+		nodes:   make([]watch.Interface, 0, watchers),
+	}
+	ctx := request.WithNamespace(context.Background(), metav1.NamespaceDefault)
+	for i := 0; i < watchers; i++ {
+		w, err := m.store.Watch(ctx, &metainternalversion.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName(i)),
+			// Serve the watch from the watch cache
+			ResourceVersion: "0",
+		})
+		if err != nil {
+			m.stop()
+			b.Fatal(err)
+		}
+		m.nodes = append(m.nodes, w)
+	}
+	return m
+}
+
+// stop stops the watchers and the storage
+func (m *machineWatchers) stop() {
+	for _, w := range m.nodes {
+		w.Stop()
+	}
+	m.store.DestroyFunc()
+}
+
+func (m *machineWatchers) benchmark(b *testing.B) {
+	ctx := request.WithNamespace(context.Background(), metav1.NamespaceDefault)
+	for i := 0; i < b.N; i++ {
+		node := m.created % watchers
+		machine := m.newFunc().(nodeMachine)
+		machine.GetObjectMeta().Name = fmt.Sprintf("machine-%d", m.created)
+		machine.GetObjectMeta().Namespace = metav1.NamespaceDefault
+		machine.setNodeName(nodeName(node))
+		m.created++
+		if _, err := m.store.Create(ctx, machine, rest.ValidateAllObjectFunc, &metav1.CreateOptions{}); err != nil {
+			b.Fatal(err)
+		}
+		if e := <-m.nodes[node].ResultChan(); e.Type != watch.Added {
+			b.Fatalf("unexpected event %v", e)
+		}
+		// Let the other watchers process the event before the next one
+		goruntime.Gosched()
+	}
+}
+
+// newMachineStorage returns the storage of the machines kept in memory behind a watch cache, the
+// default strategy indexes the fields declared by GetIndexedFields
+func newMachineStorage(resource, kind string, newFunc, newListFunc func() runtime.Object) *builders.StorageWrapper {
+	codec := builders.Codecs.LegacyCodec(benchGroupVersion)
+	etcdOptions := genericoptions.NewEtcdOptions(storagebackend.NewDefaultConfig("/registry", codec))
+	optionsGetter := &cachedRESTOptionsGetter{memory.NewRESTOptionsGetter(etcdOptions)}
+
+	r := builders.NewApiResource(
+		builders.NewInternalResource(resource, kind, newFunc, newListFunc),
+		newFunc,
+		newListFunc,
+		&builders.StorageStrategySingleton,
+	)
+	return r.Build(benchGroupVersion.Group, optionsGetter).(*builders.StorageWrapper)
+}
+
+func nodeName(i int) string {
+	return fmt.Sprintf("node-%d", i)
+}
+
+// cachedRESTOptionsGetter serves the storage of the delegate RESTOptionsGetter from a watch cache
+type cachedRESTOptionsGetter struct {
+	delegate generic.RESTOptionsGetter
+}
+
+func (g *cachedRESTOptionsGetter) GetRESTOptions(resource schema.GroupResource) (generic.RESTOptions, error) {
+	options, err := g.delegate.GetRESTOptions(resource)
+	if err != nil {
+		return generic.RESTOptions{}, err
+	}
+	decorator := options.Decorator
+	options.Decorator = func(
+		config *storagebackend.Config,
+		resourcePrefix string,
+		keyFunc func(obj runtime.Object) (string, error),
+		newFunc func() runtime.Object,
+		newListFunc func() runtime.Object,
+		getAttrsFunc storage.AttrFunc,
+		triggerFuncs storage.IndexerFuncs,
+		indexers *cache.Indexers) (storage.Interface, factory.DestroyFunc, error) {
+
+		s, destroy, err := decorator(config, resourcePrefix, keyFunc, newFunc, newListFunc, getAttrsFunc, triggerFuncs, indexers)
+		if err != nil {
+			return nil, nil, err
+		}
+		cacher, err := cacherstorage.NewCacherFromConfig(cacherstorage.Config{
+			CacheCapacity:  1000,
+			Storage:        s,
+			Versioner:      etcd3.APIObjectVersioner{},
+			ResourcePrefix: resourcePrefix,
+			KeyFunc:        keyFunc,
+			GetAttrsFunc:   getAttrsFunc,
+			IndexerFuncs:   triggerFuncs,
+			Indexers:       indexers,
+			NewFunc:        newFunc,
+			NewListFunc:    newListFunc,
+			Codec:          config.Codec,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return cacher, func() {
+			cacher.Stop()
+			destroy()
+		}, nil
+	}
+	return options, nil
+}
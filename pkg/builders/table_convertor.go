@@ -0,0 +1,189 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package builders
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metatable "k8s.io/apimachinery/pkg/api/meta/table"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+var swaggerMetadataDescriptions = metav1.ObjectMeta{}.SwaggerDoc()
+
+// TableColumn is a column printed by kubectl get, declared with the "+printcolumn" comment tag
+type TableColumn struct {
+	// Name is the column header
+	Name string
+	// Type is the OpenAPI type of the column - integer, number, string, boolean or date
+	Type string
+	// Format is the optional OpenAPI format of the column - e.g. name
+	Format string
+	// Description is the human readable description of the column
+	Description string
+	// Priority is the priority of the column, columns with a priority above 0 are only printed
+	// in wide output
+	Priority int32
+	// JSONPath is the path of the value in the versioned object - e.g. .spec.replicas
+	JSONPath string
+}
+
+var _ rest.TableConvertor = &tableConvertor{}
+
+// tableConvertor prints the name of the objects followed by the values of the columns. The
+// JSONPath of the columns are evaluated against the objects converted to the requested version.
+type tableConvertor struct {
+	headers []metav1.TableColumnDefinition
+	columns []*jsonpath.JSONPath
+}
+
+// NewTableConvertor returns a rest.TableConvertor printing the columns after the name of the
+// objects. It panics if the JSONPath of a column can't be parsed.
+func NewTableConvertor(columns ...TableColumn) rest.TableConvertor {
+	c := &tableConvertor{
+		headers: []metav1.TableColumnDefinition{
+			{Name: "Name", Type: "string", Format: "name", Description: swaggerMetadataDescriptions["name"]},
+		},
+	}
+	for _, column := range columns {
+		path := jsonpath.New(column.Name)
+		if err := path.Parse(fmt.Sprintf("{%s}", column.JSONPath)); err != nil {
+			panic(fmt.Errorf("invalid JSONPath %q for column %s: %v", column.JSONPath, column.Name, err))
+		}
+		path.AllowMissingKeys(true)
+
+		description := column.Description
+		if len(description) == 0 {
+			description = fmt.Sprintf("Column (in JSONPath format): %s", column.JSONPath)
+		}
+		c.columns = append(c.columns, path)
+		c.headers = append(c.headers, metav1.TableColumnDefinition{
+			Name:        column.Name,
+			Type:        column.Type,
+			Format:      column.Format,
+			Description: description,
+			Priority:    column.Priority,
+		})
+	}
+	return c
+}
+
+// ConvertToTable implements rest.TableConvertor.
+func (c *tableConvertor) ConvertToTable(ctx context.Context, obj runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	table := &metav1.Table{}
+	if opt, ok := tableOptions.(*metav1.TableOptions); !ok || opt == nil || !opt.NoHeaders {
+		table.ColumnDefinitions = c.headers
+	}
+
+	if m, err := meta.ListAccessor(obj); err == nil {
+		table.ResourceVersion = m.GetResourceVersion()
+		table.SelfLink = m.GetSelfLink()
+		table.Continue = m.GetContinue()
+		table.RemainingItemCount = m.GetRemainingItemCount()
+	} else if m, err := meta.CommonAccessor(obj); err == nil {
+		table.ResourceVersion = m.GetResourceVersion()
+		table.SelfLink = m.GetSelfLink()
+	}
+
+	// The columns refer to the json fields of the requested version
+	gv := requestGroupVersion(ctx)
+
+	var err error
+	buf := &bytes.Buffer{}
+	table.Rows, err = metatable.MetaToTableRow(obj, func(obj runtime.Object, m metav1.Object, name, age string) ([]interface{}, error) {
+		cells := make([]interface{}, 1, 1+len(c.columns))
+		cells[0] = name
+
+		content, err := toVersionedContent(obj, gv)
+		if err != nil {
+			return nil, err
+		}
+		headers := c.headers[1:]
+		for i, column := range c.columns {
+			results, err := column.FindResults(content)
+			if err != nil || len(results) == 0 || len(results[0]) == 0 {
+				cells = append(cells, nil)
+				continue
+			}
+			value := results[0][0].Interface()
+			if headers[i].Type == "string" {
+				if err := column.PrintResults(buf, []reflect.Value{reflect.ValueOf(value)}); err == nil {
+					cells = append(cells, buf.String())
+					buf.Reset()
+				} else {
+					cells = append(cells, nil)
+				}
+				continue
+			}
+			cells = append(cells, cellForValue(headers[i].Type, value))
+		}
+		return cells, nil
+	})
+	return table, err
+}
+
+// toVersionedContent converts the object to the version and returns its json fields
+func toVersionedContent(obj runtime.Object, gv schema.GroupVersion) (map[string]interface{}, error) {
+	if !gv.Empty() {
+		versioned, err := Scheme.ConvertToVersion(obj, gv)
+		if err != nil {
+			return nil, err
+		}
+		obj = versioned
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+func cellForValue(columnType string, value interface{}) interface{} {
+	switch columnType {
+	case "integer":
+		switch typed := value.(type) {
+		case int64:
+			return typed
+		case float64:
+			return int64(typed)
+		}
+	case "number":
+		switch typed := value.(type) {
+		case int64:
+			return float64(typed)
+		case float64:
+			return typed
+		}
+	case "boolean":
+		if b, ok := value.(bool); ok {
+			return b
+		}
+	case "date":
+		if typed, ok := value.(string); ok {
+			var timestamp metav1.Time
+			if err := timestamp.UnmarshalQueryParameter(typed); err != nil {
+				return "<invalid>"
+			}
+			return metatable.ConvertToHumanReadableDateType(timestamp)
+		}
+	}
+	return nil
+}
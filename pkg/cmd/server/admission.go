@@ -1,12 +1,109 @@
 package server
 
 import (
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apiserver/pkg/admission"
 	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/klog"
 )
 
 var (
 	AggregatedAdmissionInitializerGetter func(config *rest.Config) (admission.PluginInitializer, genericapiserver.PostStartHookFunc)
 	AggregatedAdmissionPlugins           = make(map[string]admission.Interface)
 )
+
+// ConfigurableAdmissionPlugin is an aggregated admission plugin configured by its section of the
+// --admission-control-config-file
+type ConfigurableAdmissionPlugin interface {
+	admission.Interface
+	// NewConfig returns a pointer to the configuration of the plugin, e.g. &FooConfig{}. The
+	// section of the plugin is decoded into it as yaml or json.
+	NewConfig() interface{}
+	// SetConfig validates the configuration and applies it to the plugin. The configuration is
+	// left as returned by NewConfig if the plugin has no section.
+	SetConfig(config interface{}) error
+}
+
+// registerAggregatedAdmissionPlugins registers the AggregatedAdmissionPlugins, the
+// ConfigurableAdmissionPlugins are configured once they are enabled
+func registerAggregatedAdmissionPlugins(plugins *admission.Plugins) {
+	for name, plugin := range AggregatedAdmissionPlugins {
+		name, plugin := name, plugin
+		plugins.Register(name, func(config io.Reader) (admission.Interface, error) {
+			configurable, ok := plugin.(ConfigurableAdmissionPlugin)
+			if !ok {
+				return plugin, nil
+			}
+			c := configurable.NewConfig()
+			if config != nil {
+				if err := utilyaml.NewYAMLOrJSONDecoder(config, 4096).Decode(c); err != nil {
+					return nil, fmt.Errorf("failed to decode the configuration of admission plugin %s: %v", name, err)
+				}
+			}
+			if err := configurable.SetConfig(c); err != nil {
+				return nil, fmt.Errorf("invalid configuration of admission plugin %s: %v", name, err)
+			}
+			return plugin, nil
+		})
+	}
+}
+
+// applyAdmissionTo sets up the admission chain of the server. Without a core cluster the
+// aggregated admission plugins run against the loopback client of the server, and the admission
+// plugins of the core apiserver are disabled since they depend on its resources.
+func (o ServerOptions) applyAdmissionTo(cfg *genericapiserver.Config, postStartHooks map[string]genericapiserver.PostStartHookFunc,
+	kubeConfig *rest.Config, kubeInformerFactory informers.SharedInformerFactory) error {
+
+	registerAggregatedAdmissionPlugins(o.RecommendedOptions.Admission.Plugins)
+
+	if kubeInformerFactory == nil {
+		if cfg.LoopbackClientConfig == nil {
+			klog.Info("skip admission controller initialization because neither `--kubeconfig` nor a loopback client is available")
+			return nil
+		}
+		klog.Info("running the aggregated admission controllers against the loopback client because `--kubeconfig` is not specified")
+		kubeConfig = cfg.LoopbackClientConfig
+		client, err := kubernetes.NewForConfig(kubeConfig)
+		if err != nil {
+			return err
+		}
+		// The informers of the core resources are never started
+		kubeInformerFactory = informers.NewSharedInformerFactory(client, 0)
+		o.disableCoreAdmissionPlugins()
+	}
+
+	pluginInitializers := []admission.PluginInitializer{}
+	if AggregatedAdmissionInitializerGetter != nil {
+		initializer, postStartHook := AggregatedAdmissionInitializerGetter(kubeConfig)
+		pluginInitializers = append(pluginInitializers, initializer)
+		postStartHooks["aggregated-resource-informer"] = postStartHook
+	} else {
+		klog.Warning("skip admission controller initialization because no custom admission controllers are installed")
+	}
+	return o.RecommendedOptions.Admission.ApplyTo(
+		cfg,
+		kubeInformerFactory,
+		kubeConfig,
+		o.RecommendedOptions.FeatureGate,
+		pluginInitializers...)
+}
+
+// disableCoreAdmissionPlugins disables the recommended admission plugins other than the
+// AggregatedAdmissionPlugins unless they are enabled with --enable-admission-plugins
+func (o ServerOptions) disableCoreAdmissionPlugins() {
+	admissionOptions := o.RecommendedOptions.Admission
+	enabled := sets.NewString(admissionOptions.EnablePlugins...)
+	for _, name := range admissionOptions.RecommendedPluginOrder {
+		if _, aggregated := AggregatedAdmissionPlugins[name]; aggregated || enabled.Has(name) {
+			continue
+		}
+		admissionOptions.DisablePlugins = append(admissionOptions.DisablePlugins, name)
+	}
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ServerConfigurationAPIVersion and ServerConfigurationKind identify the file of --config
+	ServerConfigurationAPIVersion = "apiserver-builder.config.k8s.io/v1alpha1"
+	ServerConfigurationKind       = "ServerConfiguration"
+)
+
+// ServerConfiguration is the configuration file of the server loaded with --config. Every field
+// stands for a flag of the server, the flags set on the command line override the file.
+type ServerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Kubeconfig is the kubeconfig of the core cluster, see --kubeconfig
+	Kubeconfig *string `json:"kubeconfig,omitempty"`
+
+	SecureServing  SecureServingConfiguration  `json:"secureServing,omitempty"`
+	Etcd           EtcdConfiguration           `json:"etcd,omitempty"`
+	Authentication AuthenticationConfiguration `json:"authentication,omitempty"`
+	Authorization  AuthorizationConfiguration  `json:"authorization,omitempty"`
+	Admission      AdmissionConfiguration      `json:"admission,omitempty"`
+
+	// RuntimeConfig enables or disables group versions and resources, see --runtime-config
+	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
+
+	// Extensions sets the other flags of the server by name, e.g. the flags added through
+	// StartOptions.FlagConfigFuncs
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+type SecureServingConfiguration struct {
+	BindAddress       *string  `json:"bindAddress,omitempty"`
+	BindPort          *int     `json:"bindPort,omitempty"`
+	CertDirectory     *string  `json:"certDirectory,omitempty"`
+	TLSCertFile       *string  `json:"tlsCertFile,omitempty"`
+	TLSPrivateKeyFile *string  `json:"tlsPrivateKeyFile,omitempty"`
+	TLSMinVersion     *string  `json:"tlsMinVersion,omitempty"`
+	TLSCipherSuites   []string `json:"tlsCipherSuites,omitempty"`
+}
+
+type EtcdConfiguration struct {
+	// StorageBackend is either etcd3 or memory, see --storage-backend
+	StorageBackend *string  `json:"storageBackend,omitempty"`
+	Servers        []string `json:"servers,omitempty"`
+	Prefix         *string  `json:"prefix,omitempty"`
+	CAFile         *string  `json:"caFile,omitempty"`
+	CertFile       *string  `json:"certFile,omitempty"`
+	KeyFile        *string  `json:"keyFile,omitempty"`
+	WatchCache     *bool    `json:"watchCache,omitempty"`
+}
+
+type AuthenticationConfiguration struct {
+	// Delegated delegates the authentication and the authorization to the core cluster, see
+	// --delegated-auth
+	Delegated                 *bool    `json:"delegated,omitempty"`
+	Kubeconfig                *string  `json:"kubeconfig,omitempty"`
+	ClientCAFile              *string  `json:"clientCAFile,omitempty"`
+	RequestHeaderClientCAFile *string  `json:"requestHeaderClientCAFile,omitempty"`
+	RequestHeaderAllowedNames []string `json:"requestHeaderAllowedNames,omitempty"`
+}
+
+type AuthorizationConfiguration struct {
+	Kubeconfig       *string  `json:"kubeconfig,omitempty"`
+	AlwaysAllowPaths []string `json:"alwaysAllowPaths,omitempty"`
+}
+
+type AdmissionConfiguration struct {
+	EnablePlugins  []string `json:"enablePlugins,omitempty"`
+	DisablePlugins []string `json:"disablePlugins,omitempty"`
+	// ConfigFile configures the admission plugins, see --admission-control-config-file
+	ConfigFile *string `json:"configFile,omitempty"`
+}
+
+// configFlag binds a field of the ServerConfiguration to its flag
+type configFlag struct {
+	name string
+	// field points to a *string, *bool, *int, []string or map[string]string field
+	field interface{}
+}
+
+func (c *ServerConfiguration) flags() []configFlag {
+	return []configFlag{
+		{"kubeconfig", &c.Kubeconfig},
+		{"bind-address", &c.SecureServing.BindAddress},
+		{"secure-port", &c.SecureServing.BindPort},
+		{"cert-dir", &c.SecureServing.CertDirectory},
+		{"tls-cert-file", &c.SecureServing.TLSCertFile},
+		{"tls-private-key-file", &c.SecureServing.TLSPrivateKeyFile},
+		{"tls-min-version", &c.SecureServing.TLSMinVersion},
+		{"tls-cipher-suites", &c.SecureServing.TLSCipherSuites},
+		{"storage-backend", &c.Etcd.StorageBackend},
+		{"etcd-servers", &c.Etcd.Servers},
+		{"etcd-prefix", &c.Etcd.Prefix},
+		{"etcd-cafile", &c.Etcd.CAFile},
+		{"etcd-certfile", &c.Etcd.CertFile},
+		{"etcd-keyfile", &c.Etcd.KeyFile},
+		{"watch-cache", &c.Etcd.WatchCache},
+		{"delegated-auth", &c.Authentication.Delegated},
+		{"authentication-kubeconfig", &c.Authentication.Kubeconfig},
+		{"client-ca-file", &c.Authentication.ClientCAFile},
+		{"requestheader-client-ca-file", &c.Authentication.RequestHeaderClientCAFile},
+		{"requestheader-allowed-names", &c.Authentication.RequestHeaderAllowedNames},
+		{"authorization-kubeconfig", &c.Authorization.Kubeconfig},
+		{"authorization-always-allow-paths", &c.Authorization.AlwaysAllowPaths},
+		{"enable-admission-plugins", &c.Admission.EnablePlugins},
+		{"disable-admission-plugins", &c.Admission.DisablePlugins},
+		{"admission-control-config-file", &c.Admission.ConfigFile},
+		{"runtime-config", &c.RuntimeConfig},
+	}
+}
+
+// applyConfigFile sets the flags of fs from the ServerConfiguration of the file, the flags set on
+// the command line are left as is
+func applyConfigFile(file string, fs *pflag.FlagSet) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	c := &ServerConfiguration{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", file, err)
+	}
+	if c.APIVersion != ServerConfigurationAPIVersion || c.Kind != ServerConfigurationKind {
+		return fmt.Errorf("%s is a %s %s, expected a %s %s", file,
+			c.APIVersion, c.Kind, ServerConfigurationAPIVersion, ServerConfigurationKind)
+	}
+
+	for _, f := range c.flags() {
+		if fs.Changed(f.name) {
+			continue
+		}
+		if err := setFlag(fs, f); err != nil {
+			return fmt.Errorf("invalid --%s in %s: %v", f.name, file, err)
+		}
+	}
+	for _, name := range sets.StringKeySet(c.Extensions).List() {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %s in the extensions of %s", name, file)
+		}
+		if fs.Changed(name) {
+			continue
+		}
+		if err := fs.Set(name, c.Extensions[name]); err != nil {
+			return fmt.Errorf("invalid --%s in %s: %v", name, file, err)
+		}
+	}
+	return nil
+}
+
+func setFlag(fs *pflag.FlagSet, f configFlag) error {
+	flag := fs.Lookup(f.name)
+	if flag == nil {
+		return fmt.Errorf("unknown flag")
+	}
+	switch field := f.field.(type) {
+	case **string:
+		if *field != nil {
+			return fs.Set(f.name, **field)
+		}
+	case **bool:
+		if *field != nil {
+			return fs.Set(f.name, strconv.FormatBool(**field))
+		}
+	case **int:
+		if *field != nil {
+			return fs.Set(f.name, strconv.Itoa(**field))
+		}
+	case *[]string:
+		if *field != nil {
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				return slice.Replace(*field)
+			}
+			return fs.Set(f.name, strings.Join(*field, ","))
+		}
+	case *map[string]string:
+		pairs := []string{}
+		for _, key := range sets.StringKeySet(*field).List() {
+			// The pairs are separated by commas, a key can't contain = either
+			if strings.ContainsAny(key, ",=") || strings.Contains((*field)[key], ",") {
+				return fmt.Errorf("invalid pair %s=%s", key, (*field)[key])
+			}
+			pairs = append(pairs, key+"="+(*field)[key])
+		}
+		if len(pairs) > 0 {
+			return fs.Set(f.name, strings.Join(pairs, ","))
+		}
+	}
+	return nil
+}
+
+// writeConfigFile writes the effective ServerConfiguration of the flags of fs to the file. The
+// flags set on the command line or by the --config file and missing from the
+// ServerConfiguration are written to its extensions.
+func writeConfigFile(file string, fs *pflag.FlagSet) error {
+	c := &ServerConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: ServerConfigurationAPIVersion,
+			Kind:       ServerConfigurationKind,
+		},
+	}
+	bound := sets.NewString("config", "write-config-to")
+	for _, f := range c.flags() {
+		bound.Insert(f.name)
+		flag := fs.Lookup(f.name)
+		if flag == nil {
+			continue
+		}
+		if err := getFlag(flag, f); err != nil {
+			return fmt.Errorf("invalid --%s: %v", f.name, err)
+		}
+	}
+	fs.Visit(func(flag *pflag.Flag) {
+		if bound.Has(flag.Name) {
+			return
+		}
+		if c.Extensions == nil {
+			c.Extensions = map[string]string{}
+		}
+		c.Extensions[flag.Name] = flagValue(flag)
+	})
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+func getFlag(flag *pflag.Flag, f configFlag) error {
+	value := flag.Value.String()
+	switch field := f.field.(type) {
+	case **string:
+		if len(value) > 0 {
+			*field = &value
+		}
+	case **bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field = &b
+	case **int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field = &i
+	case *[]string:
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			*field = slice.GetSlice()
+		} else if len(value) > 0 {
+			*field = strings.Split(value, ",")
+		}
+	case *map[string]string:
+		if len(value) == 0 {
+			return nil
+		}
+		*field = map[string]string{}
+		for _, pair := range strings.Split(value, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid pair %s", pair)
+			}
+			(*field)[kv[0]] = kv[1]
+		}
+	}
+	return nil
+}
+
+// flagValue returns the value of the flag as accepted by its Set
+func flagValue(flag *pflag.Flag) string {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return strings.Join(slice.GetSlice(), ",")
+	}
+	return flag.Value.String()
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// newConfigFlags returns the flags of the server parsed from args, with the extension flag of a
+// FlagConfigFunc
+func newConfigFlags(t *testing.T, args ...string) (*pflag.FlagSet, *ServerOptions, *string) {
+	o := NewServerOptions("/registry/test", "test", "v0.0.0", nil)
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.BoolVar(&o.RunDelegatedAuth, "delegated-auth", true, "")
+	fs.StringVar(&o.ConfigFile, "config", o.ConfigFile, "")
+	fs.StringVar(&o.WriteConfigTo, "write-config-to", o.WriteConfigTo, "")
+	o.RecommendedOptions.AddFlags(fs)
+	o.InsecureServingOptions.AddFlags(fs)
+	o.SelfRegistration.AddFlags(fs)
+	o.APIEnablement.AddFlags(fs)
+	extension := fs.String("extension", "", "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+	return fs, o, extension
+}
+
+// writeFile writes data to the file name of dir and returns its path
+func writeFile(t *testing.T, dir, name, data string) string {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "config-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+const serverConfiguration = `apiVersion: apiserver-builder.config.k8s.io/v1alpha1
+kind: ServerConfiguration
+secureServing:
+  bindPort: 8443
+  certDirectory: /var/run/certs
+etcd:
+  servers:
+  - http://etcd-0:2379
+  - http://etcd-1:2379
+  watchCache: false
+authentication:
+  delegated: false
+authorization:
+  alwaysAllowPaths:
+  - /debug/a,b
+  - /metrics
+admission:
+  enablePlugins:
+  - NamespaceLifecycle
+runtimeConfig:
+  api/alpha: "false"
+  widgets.example.com/v1/widgets: "true"
+extensions:
+  extension: value
+  self-register-service-name: widgets
+`
+
+func TestApplyConfigFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeFile(t, dir, "config.yaml", serverConfiguration)
+
+	fs, o, extension := newConfigFlags(t,
+		"--secure-port=9443",
+		"--etcd-servers=http://etcd-cli:2379",
+		"--self-register-service-name=gadgets",
+	)
+	if err := applyConfigFile(file, fs); err != nil {
+		t.Fatalf("applyConfigFile() failed: %v", err)
+	}
+
+	// The flags set on the command line win over the file
+	if got := o.RecommendedOptions.SecureServing.BindPort; got != 9443 {
+		t.Errorf("--secure-port = %v, want 9443", got)
+	}
+	if got, want := o.RecommendedOptions.Etcd.StorageConfig.Transport.ServerList, []string{"http://etcd-cli:2379"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--etcd-servers = %v, want %v", got, want)
+	}
+	if got := o.SelfRegistration.ServiceName; got != "gadgets" {
+		t.Errorf("--self-register-service-name = %v, want gadgets", got)
+	}
+
+	if got := o.RecommendedOptions.SecureServing.ServerCert.CertDirectory; got != "/var/run/certs" {
+		t.Errorf("--cert-dir = %v, want /var/run/certs", got)
+	}
+	if o.RecommendedOptions.Etcd.EnableWatchCache {
+		t.Errorf("--watch-cache = true, want false")
+	}
+	if o.RunDelegatedAuth {
+		t.Errorf("--delegated-auth = true, want false")
+	}
+	// The items of the slices are kept whole
+	if got, want := o.RecommendedOptions.Authorization.AlwaysAllowPaths, []string{"/debug/a,b", "/metrics"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--authorization-always-allow-paths = %v, want %v", got, want)
+	}
+	if got, want := o.RecommendedOptions.Admission.EnablePlugins, []string{"NamespaceLifecycle"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("--enable-admission-plugins = %v, want %v", got, want)
+	}
+	want := map[string]string{"api/alpha": "false", "widgets.example.com/v1/widgets": "true"}
+	if got := map[string]string(o.APIEnablement.RuntimeConfig); !reflect.DeepEqual(got, want) {
+		t.Errorf("--runtime-config = %v, want %v", got, want)
+	}
+	if *extension != "value" {
+		t.Errorf("--extension = %v, want value", *extension)
+	}
+}
+
+func TestSetFlagSlice(t *testing.T) {
+	fs, o, _ := newConfigFlags(t)
+	paths := []string{"/debug/a,b"}
+	// The value of Set is split on commas, Replace keeps the items
+	if err := setFlag(fs, configFlag{"authorization-always-allow-paths", &paths}); err != nil {
+		t.Fatalf("setFlag() failed: %v", err)
+	}
+	if got := o.RecommendedOptions.Authorization.AlwaysAllowPaths; !reflect.DeepEqual(got, paths) {
+		t.Errorf("--authorization-always-allow-paths = %v, want %v", got, paths)
+	}
+	// Setting the slice again replaces it instead of appending to it
+	paths = []string{"/metrics"}
+	if err := setFlag(fs, configFlag{"authorization-always-allow-paths", &paths}); err != nil {
+		t.Fatalf("setFlag() failed: %v", err)
+	}
+	if got := o.RecommendedOptions.Authorization.AlwaysAllowPaths; !reflect.DeepEqual(got, paths) {
+		t.Errorf("--authorization-always-allow-paths = %v, want %v", got, paths)
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "unknown extension",
+			data: `apiVersion: apiserver-builder.config.k8s.io/v1alpha1
+kind: ServerConfiguration
+extensions:
+  unknown-flag: value
+`,
+			wantErr: "unknown flag unknown-flag",
+		},
+		{
+			name: "invalid extension",
+			data: `apiVersion: apiserver-builder.config.k8s.io/v1alpha1
+kind: ServerConfiguration
+extensions:
+  self-register-service-port: https
+`,
+			wantErr: "invalid --self-register-service-port",
+		},
+		{
+			name: "unknown field",
+			data: `apiVersion: apiserver-builder.config.k8s.io/v1alpha1
+kind: ServerConfiguration
+secureServing:
+  port: 443
+`,
+			wantErr: "failed to decode",
+		},
+		{
+			name: "wrong kind",
+			data: `apiVersion: v1
+kind: ConfigMap
+`,
+			wantErr: "expected a apiserver-builder.config.k8s.io/v1alpha1 ServerConfiguration",
+		},
+		{
+			name: "invalid runtime config",
+			data: `apiVersion: apiserver-builder.config.k8s.io/v1alpha1
+kind: ServerConfiguration
+runtimeConfig:
+  "api/alpha,api/beta": "false"
+`,
+			wantErr: "invalid --runtime-config",
+		},
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, _, _ := newConfigFlags(t)
+			err := applyConfigFile(writeFile(t, dir, "config.yaml", tt.data), fs)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("applyConfigFile() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteConfigFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	file := writeFile(t, dir, "config.yaml", serverConfiguration)
+	written := filepath.Join(dir, "written.yaml")
+
+	fs, _, _ := newConfigFlags(t, "--secure-port=9443", "--config="+file, "--write-config-to="+written)
+	if err := applyConfigFile(file, fs); err != nil {
+		t.Fatalf("applyConfigFile() failed: %v", err)
+	}
+	if err := writeConfigFile(written, fs); err != nil {
+		t.Fatalf("writeConfigFile() failed: %v", err)
+	}
+
+	// The written file configures the same server without the command line
+	loaded, loadedOptions, loadedExtension := newConfigFlags(t)
+	if err := applyConfigFile(written, loaded); err != nil {
+		data, _ := ioutil.ReadFile(written)
+		t.Fatalf("applyConfigFile() of the written file failed: %v\n%s", err, data)
+	}
+	fs.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "config" || flag.Name == "write-config-to" {
+			return
+		}
+		if got, want := flagValue(loaded.Lookup(flag.Name)), flagValue(flag); got != want {
+			t.Errorf("--%s = %q, want %q", flag.Name, got, want)
+		}
+	})
+	if loadedOptions.RecommendedOptions.SecureServing.BindPort != 9443 || *loadedExtension != "value" {
+		t.Errorf("--secure-port, --extension = %v, %v, want 9443, value",
+			loadedOptions.RecommendedOptions.SecureServing.BindPort, *loadedExtension)
+	}
+	// The flags of the files aren't written
+	for _, name := range []string{"config", "write-config-to"} {
+		if loaded.Changed(name) {
+			t.Errorf("--%s is set by the written file", name)
+		}
+	}
+}
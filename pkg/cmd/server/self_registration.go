@@ -0,0 +1,256 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	serverstorage "k8s.io/apiserver/pkg/server/storage"
+	"k8s.io/client-go/rest"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	apiregistrationv1client "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/typed/apiregistration/v1"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+)
+
+// SelfRegistrationOptions registers the server with the kube-aggregator of the core cluster once
+// it is started
+type SelfRegistrationOptions struct {
+	// Enabled creates or updates the APIService of every group version of the server
+	Enabled bool
+	// ServiceName and ServiceNamespace locate the Service in front of the server
+	ServiceName      string
+	ServiceNamespace string
+	// ServicePort is the port of the Service serving the server
+	ServicePort int32
+	// CAFile is the CA bundle trusted by the APIServices, the self-signed serving certificate of
+	// the server is trusted if it's empty
+	CAFile string
+	// GroupPriorityMinimum is the priority of the groups of the server
+	GroupPriorityMinimum int32
+	// VersionPriority is the priority of the preferred version of every group, the following
+	// versions get decreasing priorities
+	VersionPriority int32
+}
+
+func NewSelfRegistrationOptions() *SelfRegistrationOptions {
+	return &SelfRegistrationOptions{
+		ServiceNamespace:     metav1.NamespaceDefault,
+		ServicePort:          443,
+		GroupPriorityMinimum: 2000,
+		VersionPriority:      10,
+	}
+}
+
+func (s *SelfRegistrationOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&s.Enabled, "self-register", s.Enabled,
+		"Create or update the APIServices of the server in the cluster of --kubeconfig once it is started. "+
+			"The server doesn't wait for the aggregator to report them as Available, it only logs once they are: "+
+			"the aggregator can't reach the server through its Service before it's ready")
+	fs.StringVar(&s.ServiceName, "self-register-service-name", s.ServiceName,
+		"The name of the Service in front of the server, required by --self-register")
+	fs.StringVar(&s.ServiceNamespace, "self-register-service-namespace", s.ServiceNamespace,
+		"The namespace of the Service in front of the server")
+	fs.Int32Var(&s.ServicePort, "self-register-service-port", s.ServicePort,
+		"The port of the Service in front of the server")
+	fs.StringVar(&s.CAFile, "self-register-ca-file", s.CAFile,
+		"The file of the CA bundle that signed --tls-cert-file, trusted by the APIServices. "+
+			"If empty, the serving certificate must be self-signed and is trusted instead")
+	fs.Int32Var(&s.GroupPriorityMinimum, "self-register-group-priority-minimum", s.GroupPriorityMinimum,
+		"The groupPriorityMinimum of the APIServices")
+	fs.Int32Var(&s.VersionPriority, "self-register-version-priority", s.VersionPriority,
+		"The versionPriority of the APIService of the preferred version of every group")
+}
+
+func (s *SelfRegistrationOptions) Validate() []error {
+	if s == nil || !s.Enabled {
+		return nil
+	}
+	errs := []error{}
+	if len(s.ServiceName) == 0 {
+		errs = append(errs, fmt.Errorf("--self-register-service-name is required by --self-register"))
+	}
+	if len(s.ServiceNamespace) == 0 {
+		errs = append(errs, fmt.Errorf("--self-register-service-namespace is required by --self-register"))
+	}
+	if s.ServicePort < 1 || s.ServicePort > 65535 {
+		errs = append(errs, fmt.Errorf("--self-register-service-port %v must be between 1 and 65535, inclusive", s.ServicePort))
+	}
+	if s.GroupPriorityMinimum <= 0 || s.VersionPriority <= 0 {
+		errs = append(errs, fmt.Errorf("--self-register priorities must be positive"))
+	}
+	return errs
+}
+
+// PostStartHook returns the hook creating or updating the APIServices of the group versions
+// enabled by resourceConfig through kubeConfig. The APIServices trust caBundle.
+func (s *SelfRegistrationOptions) PostStartHook(
+	kubeConfig *rest.Config,
+	caBundle []byte,
+	apis []*builders.APIGroupBuilder,
+	resourceConfig *serverstorage.ResourceConfig) (genericapiserver.PostStartHookFunc, error) {
+	client, err := apiregistrationv1client.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	apiServices := s.apiServices(caBundle, apis, resourceConfig)
+
+	return func(context genericapiserver.PostStartHookContext) error {
+		for _, apiService := range apiServices {
+			if err := createOrUpdateAPIService(client, apiService); err != nil {
+				return fmt.Errorf("failed to register APIService %s: %v", apiService.Name, err)
+			}
+			klog.Infof("registered APIService %s", apiService.Name)
+		}
+		// The hook doesn't wait for the APIServices to be available, the server isn't ready to
+		// be served behind its Service until its post start hooks are done
+		go waitForAPIServices(client, apiServices, context.StopCh)
+		return nil
+	}, nil
+}
+
+// CABundle returns the CA bundle trusted by the APIServices: the content of CAFile, or the
+// self-signed certificates of the serving certificate chain of the server
+func (s *SelfRegistrationOptions) CABundle(servingCert dynamiccertificates.CertKeyContentProvider) ([]byte, error) {
+	if len(s.CAFile) > 0 {
+		ca, err := dynamiccertificates.NewDynamicCAContentFromFile("self-register-ca", s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --self-register-ca-file: %v", err)
+		}
+		return ca.CurrentCABundleContent(), nil
+	}
+	if servingCert == nil {
+		return nil, fmt.Errorf("--self-register requires a serving certificate or --self-register-ca-file")
+	}
+	cert, _ := servingCert.CurrentCertKeyContent()
+	chain, err := certutil.ParseCertsPEM(cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the serving certificate: %v", err)
+	}
+	// The APIServices would stop trusting a serving certificate signed by another CA once it's
+	// re-issued
+	roots := []*x509.Certificate{}
+	for _, c := range chain {
+		if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil {
+			roots = append(roots, c)
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("--self-register requires --self-register-ca-file unless the serving certificate is self-signed")
+	}
+	return certutil.EncodeCertificates(roots...)
+}
+
+// apiServices returns the APIServices of the group versions of apis enabled by resourceConfig
+func (s *SelfRegistrationOptions) apiServices(
+	caBundle []byte,
+	apis []*builders.APIGroupBuilder,
+	resourceConfig *serverstorage.ResourceConfig) []*apiregistrationv1.APIService {
+	apiServices := []*apiregistrationv1.APIService{}
+	for _, api := range apis {
+		// The versions disabled by --runtime-config aren't served, the aggregator would report
+		// their APIServices as unavailable
+		index := 0
+		for _, version := range api.GetLegacyCodec() {
+			if resourceConfig != nil && !resourceConfig.VersionEnabled(version) {
+				continue
+			}
+			apiServices = append(apiServices, s.apiService(version, index, caBundle))
+			index++
+		}
+	}
+	return apiServices
+}
+
+// apiService returns the APIService of the version at the index of the enabled versions of its
+// group in preference order
+func (s *SelfRegistrationOptions) apiService(version schema.GroupVersion, index int, caBundle []byte) *apiregistrationv1.APIService {
+	versionPriority := s.VersionPriority - int32(index)
+	if versionPriority < 1 {
+		versionPriority = 1
+	}
+	port := s.ServicePort
+	return &apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: version.Version + "." + version.Group,
+		},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Group:                version.Group,
+			Version:              version.Version,
+			GroupPriorityMinimum: s.GroupPriorityMinimum,
+			VersionPriority:      versionPriority,
+			CABundle:             caBundle,
+			Service: &apiregistrationv1.ServiceReference{
+				Namespace: s.ServiceNamespace,
+				Name:      s.ServiceName,
+				Port:      &port,
+			},
+		},
+	}
+}
+
+func createOrUpdateAPIService(client apiregistrationv1client.APIServicesGetter, apiService *apiregistrationv1.APIService) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := client.APIServices().Get(context.TODO(), apiService.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = client.APIServices().Create(context.TODO(), apiService, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		existing.Spec = apiService.Spec
+		_, err = client.APIServices().Update(context.TODO(), existing, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// waitForAPIServices logs once the kube-aggregator reports the APIServices as Available
+func waitForAPIServices(client apiregistrationv1client.APIServicesGetter, apiServices []*apiregistrationv1.APIService, stopCh <-chan struct{}) {
+	for _, apiService := range apiServices {
+		err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+			current, err := client.APIServices().Get(context.TODO(), apiService.Name, metav1.GetOptions{})
+			if err != nil {
+				klog.V(2).Infof("failed to get APIService %s: %v", apiService.Name, err)
+				return false, nil
+			}
+			for _, condition := range current.Status.Conditions {
+				if condition.Type == apiregistrationv1.Available {
+					return condition.Status == apiregistrationv1.ConditionTrue, nil
+				}
+			}
+			return false, nil
+		}, stopCh)
+		if err != nil {
+			return
+		}
+		klog.Infof("APIService %s is available", apiService.Name)
+	}
+}
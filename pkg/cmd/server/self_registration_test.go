@@ -0,0 +1,239 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	serverstorage "k8s.io/apiserver/pkg/server/storage"
+	certutil "k8s.io/client-go/util/cert"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+)
+
+var (
+	widgetsV1      = schema.GroupVersion{Group: "widgets.example.com", Version: "v1"}
+	widgetsV1beta1 = schema.GroupVersion{Group: "widgets.example.com", Version: "v1beta1"}
+	widgetsV1alpha = schema.GroupVersion{Group: "widgets.example.com", Version: "v1alpha1"}
+)
+
+func newGroup(versions ...schema.GroupVersion) *builders.APIGroupBuilder {
+	group := builders.NewApiGroupBuilder(versions[0].Group, "")
+	for _, version := range versions {
+		group.Versions = append(group.Versions, &builders.VersionedApiBuilder{GroupVersion: version})
+	}
+	return group
+}
+
+func TestSelfRegistrationAPIServices(t *testing.T) {
+	apis := []*builders.APIGroupBuilder{newGroup(widgetsV1, widgetsV1beta1, widgetsV1alpha)}
+	tests := []struct {
+		name     string
+		disabled []schema.GroupVersion
+		want     map[string]int32
+	}{
+		{
+			name: "all versions",
+			want: map[string]int32{"v1.widgets.example.com": 10, "v1beta1.widgets.example.com": 9, "v1alpha1.widgets.example.com": 8},
+		},
+		{
+			name:     "disabled preferred version",
+			disabled: []schema.GroupVersion{widgetsV1},
+			want:     map[string]int32{"v1beta1.widgets.example.com": 10, "v1alpha1.widgets.example.com": 9},
+		},
+		{
+			name:     "disabled version",
+			disabled: []schema.GroupVersion{widgetsV1beta1},
+			want:     map[string]int32{"v1.widgets.example.com": 10, "v1alpha1.widgets.example.com": 9},
+		},
+		{
+			name:     "disabled group",
+			disabled: []schema.GroupVersion{widgetsV1, widgetsV1beta1, widgetsV1alpha},
+			want:     map[string]int32{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resourceConfig := serverstorage.NewResourceConfig()
+			resourceConfig.EnableVersions(widgetsV1, widgetsV1beta1, widgetsV1alpha)
+			resourceConfig.DisableVersions(tt.disabled...)
+
+			s := NewSelfRegistrationOptions()
+			got := map[string]int32{}
+			for _, apiService := range s.apiServices([]byte("ca"), apis, resourceConfig) {
+				got[apiService.Name] = apiService.Spec.VersionPriority
+				if string(apiService.Spec.CABundle) != "ca" {
+					t.Errorf("APIService %s caBundle = %q, want ca", apiService.Name, apiService.Spec.CABundle)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apiServices() version priorities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfRegistrationValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(s *SelfRegistrationOptions)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			modify: func(s *SelfRegistrationOptions) {},
+		},
+		{
+			name:   "disabled",
+			modify: func(s *SelfRegistrationOptions) { s.Enabled, s.ServiceName = false, "" },
+		},
+		{
+			name:    "missing service name",
+			modify:  func(s *SelfRegistrationOptions) { s.ServiceName = "" },
+			wantErr: "--self-register-service-name is required",
+		},
+		{
+			name:    "zero port",
+			modify:  func(s *SelfRegistrationOptions) { s.ServicePort = 0 },
+			wantErr: "--self-register-service-port 0 must be between 1 and 65535",
+		},
+		{
+			name:    "port out of range",
+			modify:  func(s *SelfRegistrationOptions) { s.ServicePort = 65536 },
+			wantErr: "--self-register-service-port 65536 must be between 1 and 65535",
+		},
+		{
+			name:    "negative priority",
+			modify:  func(s *SelfRegistrationOptions) { s.VersionPriority = -1 },
+			wantErr: "priorities must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSelfRegistrationOptions()
+			s.Enabled, s.ServiceName = true, "widgets"
+			tt.modify(s)
+			errs := s.Validate()
+			if len(tt.wantErr) == 0 {
+				if len(errs) > 0 {
+					t.Errorf("Validate() = %v, want no errors", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want %q", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelfRegistrationCABundle(t *testing.T) {
+	// The generated certificate is signed by a generated self-signed CA
+	chain, key, err := certutil.GenerateSelfSignedCertKey("widgets.default.svc", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := certutil.ParseCertsPEM(chain)
+	if err != nil || len(certs) != 2 {
+		t.Fatalf("ParseCertsPEM() = %v, %v, want a certificate and its CA", certs, err)
+	}
+	leaf, err := certutil.EncodeCertificates(certs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := certutil.EncodeCertificates(certs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "self-registration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	servingCert := func(cert []byte) dynamiccertificates.CertKeyContentProvider {
+		provider, err := dynamiccertificates.NewStaticCertKeyContent("serving-cert", cert, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return provider
+	}
+
+	tests := []struct {
+		name        string
+		caFile      string
+		servingCert dynamiccertificates.CertKeyContentProvider
+		want        []byte
+		wantErr     string
+	}{
+		{
+			name:        "ca file",
+			caFile:      caFile,
+			servingCert: servingCert(leaf),
+			want:        ca,
+		},
+		{
+			name:        "missing ca file",
+			caFile:      filepath.Join(dir, "missing.crt"),
+			servingCert: servingCert(chain),
+			wantErr:     "failed to load --self-register-ca-file",
+		},
+		{
+			name:        "self-signed chain",
+			servingCert: servingCert(chain),
+			want:        ca,
+		},
+		{
+			name:        "certificate signed by a CA",
+			servingCert: servingCert(leaf),
+			wantErr:     "requires --self-register-ca-file",
+		},
+		{
+			name:    "no serving certificate",
+			wantErr: "requires a serving certificate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSelfRegistrationOptions()
+			s.CAFile = tt.caFile
+			got, err := s.CABundle(tt.servingCert)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("CABundle() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CABundle() failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("CABundle() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
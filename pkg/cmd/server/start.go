@@ -29,16 +29,17 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
-	"k8s.io/apiserver/pkg/admission"
 	genericapifilters "k8s.io/apiserver/pkg/endpoints/filters"
 	openapinamer "k8s.io/apiserver/pkg/endpoints/openapi"
 	"k8s.io/apiserver/pkg/features"
 	"k8s.io/apiserver/pkg/server"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	genericfilters "k8s.io/apiserver/pkg/server/filters"
+	"k8s.io/apiserver/pkg/server/healthz"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
 	"k8s.io/apiserver/pkg/server/storage"
 	"k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -47,7 +48,9 @@ import (
 	openapi "k8s.io/kube-openapi/pkg/common"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/apiserver"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/storage/memory"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/validators"
+	builderversion "sigs.k8s.io/apiserver-builder-alpha/pkg/version"
 )
 
 var GetOpenApiDefinition openapi.GetOpenAPIDefinitions
@@ -56,12 +59,21 @@ type ServerOptions struct {
 	RecommendedOptions     *genericoptions.RecommendedOptions
 	APIBuilders            []*builders.APIGroupBuilder
 	InsecureServingOptions *genericoptions.DeprecatedInsecureServingOptionsWithLoopback
+	APIEnablement          *genericoptions.APIEnablementOptions
 
 	PrintBearerToken bool
 	PrintOpenapi     bool
 	RunDelegatedAuth bool
 	BearerToken      string
 	PostStartHooks   []PostStartHook
+
+	// ConfigFile is the ServerConfiguration loaded before the flags are applied
+	ConfigFile string
+	// WriteConfigTo is the file the effective ServerConfiguration is written to instead of
+	// running the server
+	WriteConfigTo string
+
+	SelfRegistration *SelfRegistrationOptions
 }
 
 type PostStartHook struct {
@@ -77,6 +89,20 @@ type StartOptions struct {
 	Version          string
 	TweakConfigFuncs []func(apiServer *apiserver.Config) error
 
+	// StorageBackends overrides the storage backend of individual resources, e.g. to persist
+	// one resource to an embedded SQL store while the others are kept in etcd
+	StorageBackends map[schema.GroupResource]builders.StorageBackend
+
+	// HealthChecks are added to the healthz, livez and readyz endpoints of the server
+	HealthChecks []healthz.HealthChecker
+	// ReadyzChecks are only added to the readyz endpoint, e.g. to stop serving while a downstream
+	// service is unavailable without restarting the server
+	ReadyzChecks []healthz.HealthChecker
+
+	// NonResourceHandlers serve the paths that aren't resources of the server, e.g.
+	// /apis/<group>/<version>/-/webhooks or /debug/<feature>
+	NonResourceHandlers []apiserver.NonResourceHandler
+
 	//FlagConfigFunc handles user-defined flags
 	FlagConfigFuncs []func(*cobra.Command) error
 }
@@ -98,11 +124,33 @@ func StartApiServer(etcdPath string, apis []*builders.APIGroupBuilder, openapide
 func StartApiServerWithOptions(opts *StartOptions) error {
 
 	GetOpenApiDefinition = opts.Openapidefs
+	for groupResource, backend := range opts.StorageBackends {
+		builders.ResourceStorageBackends[groupResource] = backend
+	}
+
+	tweakConfigFuncs := append([]func(apiServer *apiserver.Config) error{}, opts.TweakConfigFuncs...)
+	if len(opts.HealthChecks) > 0 || len(opts.ReadyzChecks) > 0 {
+		tweakConfigFuncs = append(tweakConfigFuncs, func(apiServer *apiserver.Config) error {
+			config := &apiServer.RecommendedConfig.Config
+			config.HealthzChecks = append(config.HealthzChecks, opts.HealthChecks...)
+			config.LivezChecks = append(config.LivezChecks, opts.HealthChecks...)
+			config.ReadyzChecks = append(config.ReadyzChecks, opts.HealthChecks...)
+			config.ReadyzChecks = append(config.ReadyzChecks, opts.ReadyzChecks...)
+			return nil
+		})
+	}
+
+	if len(opts.NonResourceHandlers) > 0 {
+		tweakConfigFuncs = append(tweakConfigFuncs, func(apiServer *apiserver.Config) error {
+			apiServer.NonResourceHandlers = append(apiServer.NonResourceHandlers, opts.NonResourceHandlers...)
+			return nil
+		})
+	}
 
 	signalCh := genericapiserver.SetupSignalHandler()
 	// To disable providers, manually specify the list provided by getKnownProviders()
 	cmd, _ := NewCommandStartServer(opts.EtcdPath, os.Stdout, os.Stderr, opts.Apis, signalCh,
-		opts.Title, opts.Version, opts.TweakConfigFuncs...)
+		opts.Title, opts.Version, tweakConfigFuncs...)
 
 	errors := []error{}
 	for _, ff := range opts.FlagConfigFuncs {
@@ -137,6 +185,8 @@ func NewServerOptions(etcdPath, title, version string, b []*builders.APIGroupBui
 		),
 		APIBuilders:      b,
 		RunDelegatedAuth: true,
+		SelfRegistration: NewSelfRegistrationOptions(),
+		APIEnablement:    genericoptions.NewAPIEnablementOptions(),
 	}
 	o.RecommendedOptions.SecureServing.BindPort = 443
 
@@ -165,6 +215,14 @@ func NewCommandStartServer(etcdPath string, out, errOut io.Writer, builders []*b
 		Short: "Launch an API server",
 		Long:  "Launch an API server",
 		RunE: func(c *cobra.Command, args []string) error {
+			if len(o.ConfigFile) > 0 {
+				if err := applyConfigFile(o.ConfigFile, c.Flags()); err != nil {
+					return err
+				}
+			}
+			if len(o.WriteConfigTo) > 0 {
+				return writeConfigFile(o.WriteConfigTo, c.Flags())
+			}
 
 			// TODO: remove it after upgrading to 1.13+
 			// Sync the glog and klog flags.
@@ -196,8 +254,18 @@ func NewCommandStartServer(etcdPath string, out, errOut io.Writer, builders []*b
 		"Print the openapi json and exit")
 	flags.BoolVar(&o.RunDelegatedAuth, "delegated-auth", true,
 		"Setup delegated auth")
+	flags.StringVar(&o.ConfigFile, "config", o.ConfigFile,
+		"The ServerConfiguration file of the server, the flags set on the command line override its values")
+	flags.StringVar(&o.WriteConfigTo, "write-config-to", o.WriteConfigTo,
+		"Write the effective ServerConfiguration of the flags and --config to this file and exit")
 	o.RecommendedOptions.AddFlags(flags)
+	flags.Lookup("storage-backend").Usage = fmt.Sprintf(
+		"The storage backend for persistence. Options: 'etcd3' (default), '%s'. "+
+			"The '%s' storage keeps objects in the server process and loses them on restart.",
+		memory.StorageTypeMemory, memory.StorageTypeMemory)
 	o.InsecureServingOptions.AddFlags(flags)
+	o.SelfRegistration.AddFlags(flags)
+	o.APIEnablement.AddFlags(flags)
 
 	feature.DefaultMutableFeatureGate.AddFlag(flags)
 
@@ -208,7 +276,9 @@ func NewCommandStartServer(etcdPath string, out, errOut io.Writer, builders []*b
 }
 
 func (o ServerOptions) Validate(args []string) error {
-	return nil
+	errors := o.SelfRegistration.Validate()
+	errors = append(errors, o.APIEnablement.Validate(builders.Scheme)...)
+	return utilerrors.NewAggregate(errors)
 }
 
 func (o *ServerOptions) Complete() error {
@@ -278,14 +348,25 @@ func (o ServerOptions) Config(tweakConfigFuncs ...func(config *apiserver.Config)
 		return nil, err
 	}
 
+	// The group versions and resources disabled by --runtime-config aren't served
+	serverConfig.MergedResourceConfig, err = builders.NewAPIResourceConfig(o.APIBuilders, o.APIEnablement.RuntimeConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	var insecureServingInfo *genericapiserver.DeprecatedInsecureServingInfo
 	if err := o.InsecureServingOptions.ApplyTo(&insecureServingInfo, &serverConfig.LoopbackClientConfig); err != nil {
 		return nil, err
 	}
+	restContext, err := o.buildRESTContext(serverConfig, loopbackKubeConfig, kubeInformerFactory)
+	if err != nil {
+		return nil, err
+	}
 	config := &apiserver.Config{
 		RecommendedConfig:   serverConfig,
 		InsecureServingInfo: insecureServingInfo,
 		PostStartHooks:      make(map[string]genericapiserver.PostStartHookFunc),
+		RESTContext:         restContext,
 	}
 
 	if o.RunDelegatedAuth {
@@ -298,40 +379,16 @@ func (o ServerOptions) Config(tweakConfigFuncs ...func(config *apiserver.Config)
 				return o.RecommendedOptions.Authorization.ApplyTo(&cfg.Authorization)
 			},
 			func(cfg *genericapiserver.Config) error {
-				if kubeInformerFactory != nil {
-					pluginInitializers := []admission.PluginInitializer{}
-					if AggregatedAdmissionInitializerGetter != nil {
-						initializer, postStartHook := AggregatedAdmissionInitializerGetter(loopbackKubeConfig)
-						pluginInitializers = append(pluginInitializers, initializer)
-						config.PostStartHooks["aggregated-resource-informer"] = postStartHook
-					} else {
-						klog.Warning("skip admission controller initialization because no custom admission controllers are installed")
-					}
-					for name, plugin := range AggregatedAdmissionPlugins {
-						plugin := plugin
-						o.RecommendedOptions.Admission.Plugins.Register(name, func(config io.Reader) (admission.Interface, error) {
-							// TODO(yue9944882): support admission controller config file reading
-							return plugin, nil
-						})
-					}
-					return o.RecommendedOptions.Admission.ApplyTo(
-						cfg,
-						kubeInformerFactory,
-						loopbackKubeConfig,
-						o.RecommendedOptions.FeatureGate,
-						pluginInitializers...)
-				} else {
-					klog.Info("skip admission controller initialization because `--kubeconfig` is not specified")
+				if o.usesInMemoryStorage() {
+					cfg.RESTOptionsGetter = memory.NewRESTOptionsGetter(o.RecommendedOptions.Etcd)
+					return nil
 				}
-				return nil
-			},
-			func(cfg *genericapiserver.Config) error {
 				storageFactory := storage.NewDefaultStorageFactory(
 					o.RecommendedOptions.Etcd.StorageConfig,
 					o.RecommendedOptions.Etcd.DefaultStorageMediaType,
 					builders.Codecs,
 					storage.NewDefaultResourceEncodingConfig(builders.Scheme),
-					storage.NewResourceConfig(),
+					cfg.MergedResourceConfig,
 					make(map[schema.GroupResource]string),
 				)
 				return o.RecommendedOptions.Etcd.ApplyWithStorageFactoryTo(storageFactory, cfg)
@@ -340,6 +397,8 @@ func (o ServerOptions) Config(tweakConfigFuncs ...func(config *apiserver.Config)
 		if err != nil {
 			return nil, err
 		}
+	} else if o.usesInMemoryStorage() {
+		serverConfig.RESTOptionsGetter = memory.NewRESTOptionsGetter(o.RecommendedOptions.Etcd)
 	} else {
 		err := o.RecommendedOptions.Etcd.ApplyTo(&serverConfig.Config)
 		if err != nil {
@@ -347,6 +406,29 @@ func (o ServerOptions) Config(tweakConfigFuncs ...func(config *apiserver.Config)
 		}
 	}
 
+	// Admission runs with or without delegated auth
+	if err := o.applyAdmissionTo(&serverConfig.Config, config.PostStartHooks, loopbackKubeConfig, kubeInformerFactory); err != nil {
+		return nil, err
+	}
+
+	if o.SelfRegistration != nil && o.SelfRegistration.Enabled {
+		if loopbackKubeConfig == nil {
+			return nil, fmt.Errorf("--self-register requires --kubeconfig or an in-cluster client")
+		}
+		if serverConfig.SecureServing == nil {
+			return nil, fmt.Errorf("--self-register requires secure serving")
+		}
+		caBundle, err := o.SelfRegistration.CABundle(serverConfig.SecureServing.Cert)
+		if err != nil {
+			return nil, err
+		}
+		hook, err := o.SelfRegistration.PostStartHook(loopbackKubeConfig, caBundle, o.APIBuilders, serverConfig.MergedResourceConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.PostStartHooks["self-register-apiservices"] = hook
+	}
+
 	for _, tweakConfigFunc := range tweakConfigFuncs {
 		if err := tweakConfigFunc(config); err != nil {
 			return nil, err
@@ -355,6 +437,11 @@ func (o ServerOptions) Config(tweakConfigFuncs ...func(config *apiserver.Config)
 	return config, nil
 }
 
+// usesInMemoryStorage returns true if the server keeps its resources in memory instead of etcd
+func (o ServerOptions) usesInMemoryStorage() bool {
+	return o.RecommendedOptions.Etcd.StorageConfig.Type == memory.StorageTypeMemory
+}
+
 func (o *ServerOptions) buildLoopback() (*rest.Config, informers.SharedInformerFactory, error) {
 	var loopbackConfig *rest.Config
 	var err error
@@ -380,6 +467,34 @@ func (o *ServerOptions) buildLoopback() (*rest.Config, informers.SharedInformerF
 	return loopbackConfig, kubeInformerFactory, nil
 }
 
+// buildRESTContext returns the clients passed to the REST of the resources, the clients of the
+// core cluster are left nil when the loopback client couldn't be built
+func (o *ServerOptions) buildRESTContext(
+	serverConfig *genericapiserver.RecommendedConfig,
+	kubeConfig *rest.Config,
+	kubeInformerFactory informers.SharedInformerFactory) (*builders.RESTContext, error) {
+	ctx := &builders.RESTContext{
+		LoopbackClientConfig: serverConfig.LoopbackClientConfig,
+	}
+	if kubeConfig != nil {
+		kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+		if err != nil {
+			return nil, err
+		}
+		ctx.KubeConfig = kubeConfig
+		ctx.KubeClient = kubeClient
+		ctx.KubeInformerFactory = kubeInformerFactory
+	}
+	if serverConfig.LoopbackClientConfig != nil {
+		aggregatedClient, err := dynamic.NewForConfig(serverConfig.LoopbackClientConfig)
+		if err != nil {
+			return nil, err
+		}
+		ctx.AggregatedClient = aggregatedClient
+	}
+	return ctx, nil
+}
+
 func (o *ServerOptions) RunServer(stopCh <-chan struct{}, title, version string, tweakConfigFuncs ...func(apiserver *apiserver.Config) error) error {
 	aggregatedAPIServerConfig, err := o.Config(tweakConfigFuncs...)
 	if err != nil {
@@ -402,10 +517,24 @@ func (o *ServerOptions) RunServer(stopCh <-chan struct{}, title, version string,
 
 	aggregatedAPIServerConfig.Init()
 
-	genericConfig.OpenAPIConfig = genericapiserver.DefaultOpenAPIConfig(GetOpenApiDefinition, openapinamer.NewDefinitionNamer(builders.Scheme))
+	// The defaults of the +default comment tags and the +validation:rule comment tags are served
+	// with the definitions of the APIs
+	getOpenAPIDefinitions := builders.WithOpenAPIValidationRules(
+		builders.WithOpenAPIDefaults(GetOpenApiDefinition, o.APIBuilders), o.APIBuilders)
+	genericConfig.OpenAPIConfig = genericapiserver.DefaultOpenAPIConfig(
+		getOpenAPIDefinitions, openapinamer.NewDefinitionNamer(builders.Scheme))
 	genericConfig.OpenAPIConfig.Info.Title = title
 	genericConfig.OpenAPIConfig.Info.Version = version
 
+	if aggregatedAPIServerConfig.RESTContext != nil {
+		aggregatedAPIServerConfig.RESTContext.StopCh = stopCh
+	}
+
+	if aggregatedAPIServerConfig.Version == nil {
+		versionInfo := builderversion.Get(version)
+		aggregatedAPIServerConfig.Version = &versionInfo
+	}
+
 	genericServer, err := aggregatedAPIServerConfig.Complete().New()
 	if err != nil {
 		return err
@@ -418,6 +547,7 @@ func (o *ServerOptions) RunServer(stopCh <-chan struct{}, title, version string,
 	}
 
 	s := genericServer.GenericAPIServer.PrepareRun()
+	// The served schema is parsed once and used to validate created and updated objects
 	err = validators.OpenAPI.SetSchema(readOpenapi(genericConfig.LoopbackClientConfig.BearerToken, genericServer.GenericAPIServer.Handler))
 	if o.PrintOpenapi {
 		fmt.Printf("%s", validators.OpenAPI.OpenAPI)
@@ -428,7 +558,7 @@ func (o *ServerOptions) RunServer(stopCh <-chan struct{}, title, version string,
 	}
 
 	if aggregatedAPIServerConfig.InsecureServingInfo != nil {
-		handler := s.GenericAPIServer.UnprotectedHandler()
+		handler := genericServer.WithNonResourceHandlers(genericServer.WithResourceMetrics(s.GenericAPIServer.UnprotectedHandler()))
 		handler = genericapifilters.WithAudit(handler, genericConfig.AuditBackend, genericConfig.AuditPolicyChecker, genericConfig.LongRunningFunc)
 		handler = genericapifilters.WithAuthentication(handler, server.InsecureSuperuser{}, nil, nil)
 		handler = genericfilters.WithCORS(handler, genericConfig.CorsAllowedOriginList, nil, nil, nil, "true")
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package memory implements an in-process storage.Interface for development and tests. Its
+// contents are lost when the server stops.
+package memory
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/registry/generic"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
+	"k8s.io/apiserver/pkg/storage/storagebackend/factory"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
+)
+
+// StorageTypeMemory is the value of the --storage-backend flag selecting the in-memory storage
+const StorageTypeMemory = "memory"
+
+// NewRESTOptionsGetter returns a RESTOptionsGetter keeping every resource in a single in-memory
+// store. It replaces the etcd options when the server runs with --storage-backend=memory.
+func NewRESTOptionsGetter(etcdOptions *genericoptions.EtcdOptions) generic.RESTOptionsGetter {
+	return &restOptionsGetter{
+		options: etcdOptions,
+		store:   newStore(),
+	}
+}
+
+// NewStorageBackend returns a storage backend keeping the resources selecting it in memory
+// while the others are persisted to the default storage.
+func NewStorageBackend() builders.StorageBackend {
+	s := newStore()
+	return func(delegate generic.RESTOptionsGetter) generic.RESTOptionsGetter {
+		return &delegatingRESTOptionsGetter{
+			delegate: delegate,
+			store:    s,
+		}
+	}
+}
+
+type restOptionsGetter struct {
+	options *genericoptions.EtcdOptions
+	store   *store
+}
+
+func (g *restOptionsGetter) GetRESTOptions(resource schema.GroupResource) (generic.RESTOptions, error) {
+	storageConfig := g.options.StorageConfig
+	return generic.RESTOptions{
+		StorageConfig:           &storageConfig,
+		Decorator:               g.store.decorate,
+		EnableGarbageCollection: g.options.EnableGarbageCollection,
+		DeleteCollectionWorkers: g.options.DeleteCollectionWorkers,
+		ResourcePrefix:          resource.Group + "/" + resource.Resource,
+		CountMetricPollPeriod:   g.options.StorageConfig.CountMetricPollPeriod,
+	}, nil
+}
+
+type delegatingRESTOptionsGetter struct {
+	delegate generic.RESTOptionsGetter
+	store    *store
+}
+
+func (g *delegatingRESTOptionsGetter) GetRESTOptions(resource schema.GroupResource) (generic.RESTOptions, error) {
+	options, err := g.delegate.GetRESTOptions(resource)
+	if err != nil {
+		return generic.RESTOptions{}, err
+	}
+	options.Decorator = g.store.decorate
+	return options, nil
+}
+
+// decorate implements generic.StorageDecorator. Every resource shares the same store, so that
+// e.g. the status subresource sees the objects written through the main resource.
+func (s *store) decorate(
+	config *storagebackend.Config,
+	resourcePrefix string,
+	keyFunc func(obj runtime.Object) (string, error),
+	newFunc func() runtime.Object,
+	newListFunc func() runtime.Object,
+	getAttrsFunc storage.AttrFunc,
+	trigger storage.IndexerFuncs,
+	indexers *cache.Indexers) (storage.Interface, factory.DestroyFunc, error) {
+	return s, func() {}, nil
+}
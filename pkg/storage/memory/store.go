@@ -0,0 +1,421 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/etcd3"
+)
+
+// historySize is the number of events kept to serve watches starting from an older resourceVersion
+const historySize = 1000
+
+var _ storage.Interface = &store{}
+
+// store is an in-memory implementation of storage.Interface. Objects are kept as deep copies
+// keyed by their storage key, every write bumps a single revision counter shared by all keys
+// in the same way etcd does.
+type store struct {
+	lock      sync.RWMutex
+	versioner storage.Versioner
+
+	revision uint64
+	objects  map[string]*record
+
+	// history is a ring of the most recent events used to replay watches
+	history  []*event
+	watchers map[int]*watcher
+	nextID   int
+}
+
+type record struct {
+	obj      runtime.Object
+	revision uint64
+}
+
+func newStore() *store {
+	return &store{
+		versioner: etcd3.APIObjectVersioner{},
+		// etcd starts at revision 1, the watch cache can't serve watches from an empty list at 0
+		revision: 1,
+		objects:  map[string]*record{},
+		watchers: map[int]*watcher{},
+	}
+}
+
+// Versioner implements storage.Interface.
+func (s *store) Versioner() storage.Versioner {
+	return s.versioner
+}
+
+// Create implements storage.Interface.
+func (s *store) Create(ctx context.Context, key string, obj, out runtime.Object, ttl uint64) error {
+	if version, err := s.versioner.ObjectResourceVersion(obj); err == nil && version != 0 {
+		return fmt.Errorf("resourceVersion should not be set on objects to be created")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if existing, found := s.objects[key]; found {
+		return storage.NewKeyExistsError(key, int64(existing.revision))
+	}
+	stored, err := s.put(key, obj)
+	if err != nil {
+		return err
+	}
+	s.notify(&event{key: key, obj: stored, revision: s.revision, isCreated: true})
+	return copyInto(stored, out)
+}
+
+// Delete implements storage.Interface.
+func (s *store) Delete(ctx context.Context, key string, out runtime.Object, preconditions *storage.Preconditions, validateDeletion storage.ValidateObjectFunc) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	existing, found := s.objects[key]
+	if !found {
+		return storage.NewKeyNotFoundError(key, 0)
+	}
+	current := existing.obj.DeepCopyObject()
+	if err := preconditions.Check(key, current); err != nil {
+		return err
+	}
+	if err := validateDeletion(ctx, current); err != nil {
+		return err
+	}
+
+	delete(s.objects, key)
+	s.revision++
+	s.notify(&event{key: key, prevObj: existing.obj, revision: s.revision, isDeleted: true})
+	return copyInto(existing.obj, out)
+}
+
+// Watch implements storage.Interface.
+func (s *store) Watch(ctx context.Context, key string, resourceVersion string, p storage.SelectionPredicate) (watch.Interface, error) {
+	return s.watch(ctx, key, resourceVersion, p, false)
+}
+
+// WatchList implements storage.Interface.
+func (s *store) WatchList(ctx context.Context, key string, resourceVersion string, p storage.SelectionPredicate) (watch.Interface, error) {
+	return s.watch(ctx, key, resourceVersion, p, true)
+}
+
+// Get implements storage.Interface.
+func (s *store) Get(ctx context.Context, key string, resourceVersion string, objPtr runtime.Object, ignoreNotFound bool) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	existing, found := s.objects[key]
+	if !found {
+		if ignoreNotFound {
+			return runtime.SetZeroValue(objPtr)
+		}
+		return storage.NewKeyNotFoundError(key, 0)
+	}
+	return copyInto(existing.obj, objPtr)
+}
+
+// GetToList implements storage.Interface.
+func (s *store) GetToList(ctx context.Context, key string, resourceVersion string, p storage.SelectionPredicate, listObj runtime.Object) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	items := []runtime.Object{}
+	if existing, found := s.objects[key]; found {
+		matches, err := p.Matches(existing.obj)
+		if err != nil {
+			return err
+		}
+		if matches {
+			items = append(items, existing.obj.DeepCopyObject())
+		}
+	}
+	return s.setList(listObj, items, "", nil)
+}
+
+// List implements storage.Interface. Lists are split in pages of at most the predicate limit of
+// objects, the continue token has the format of the etcd storage. The store keeps no snapshots,
+// so the next pages list the latest state of the objects after the previous page.
+func (s *store) List(ctx context.Context, key string, resourceVersion string, p storage.SelectionPredicate, listObj runtime.Object) error {
+	prefix := key
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	start := prefix
+	if len(p.Continue) > 0 {
+		if len(resourceVersion) > 0 && resourceVersion != "0" {
+			return apierrors.NewBadRequest("specifying resource version is not allowed when using continue")
+		}
+		var err error
+		if start, err = decodeContinue(p.Continue, prefix); err != nil {
+			return apierrors.NewBadRequest(fmt.Sprintf("invalid continue token: %v", err))
+		}
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := []string{}
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) && k >= start {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	items := []runtime.Object{}
+	for i, k := range keys {
+		if p.Limit > 0 && int64(len(items)) == p.Limit {
+			// Like etcd the next page starts after the last listed key
+			next, err := encodeContinue(keys[i-1]+"\x00", prefix, int64(s.revision))
+			if err != nil {
+				return err
+			}
+			var remaining *int64
+			if p.Empty() {
+				count := int64(len(keys) - i)
+				remaining = &count
+			}
+			return s.setList(listObj, items, next, remaining)
+		}
+		obj := s.objects[k].obj
+		matches, err := p.Matches(obj)
+		if err != nil {
+			return err
+		}
+		if matches {
+			items = append(items, obj.DeepCopyObject())
+		}
+	}
+	return s.setList(listObj, items, "", nil)
+}
+
+// GuaranteedUpdate implements storage.Interface.
+func (s *store) GuaranteedUpdate(
+	ctx context.Context, key string, ptrToType runtime.Object, ignoreNotFound bool,
+	preconditions *storage.Preconditions, tryUpdate storage.UpdateFunc, suggestion ...runtime.Object) error {
+
+	for {
+		current, revision, err := s.getForUpdate(key, ptrToType, ignoreNotFound)
+		if err != nil {
+			return err
+		}
+		if revision != 0 {
+			if err := preconditions.Check(key, current); err != nil {
+				return err
+			}
+		}
+
+		updated, _, err := tryUpdate(current.DeepCopyObject(), storage.ResponseMeta{ResourceVersion: revision})
+		if err != nil {
+			return err
+		}
+
+		done, err := s.tryCommit(key, revision, updated, ptrToType)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		// The object has been changed by another writer, retry against its latest state
+	}
+}
+
+// Count implements storage.Interface.
+func (s *store) Count(key string) (int64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	prefix := key
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	count := int64(0)
+	for k := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// getForUpdate returns a copy of the object stored under key and its revision, or a zero value
+// object and a zero revision if the key doesn't exist and ignoreNotFound is set.
+func (s *store) getForUpdate(key string, ptrToType runtime.Object, ignoreNotFound bool) (runtime.Object, uint64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	existing, found := s.objects[key]
+	if !found {
+		if !ignoreNotFound {
+			return nil, 0, storage.NewKeyNotFoundError(key, 0)
+		}
+		zero := ptrToType.DeepCopyObject()
+		if err := runtime.SetZeroValue(zero); err != nil {
+			return nil, 0, err
+		}
+		return zero, 0, nil
+	}
+	return existing.obj.DeepCopyObject(), existing.revision, nil
+}
+
+// tryCommit stores updated under key if the key is still at revision. It returns false if the key
+// has been modified concurrently.
+func (s *store) tryCommit(key string, revision uint64, updated, out runtime.Object) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	existing, found := s.objects[key]
+	switch {
+	case found && existing.revision != revision:
+		return false, nil
+	case !found && revision != 0:
+		return false, nil
+	}
+
+	if found {
+		unchanged, err := s.semanticallyEqual(existing.obj, updated)
+		if err != nil {
+			return false, err
+		}
+		if unchanged {
+			return true, copyInto(existing.obj, out)
+		}
+	}
+
+	stored, err := s.put(key, updated)
+	if err != nil {
+		return false, err
+	}
+	e := &event{key: key, obj: stored, revision: s.revision, isCreated: !found}
+	if found {
+		e.prevObj = existing.obj
+	}
+	s.notify(e)
+	return true, copyInto(stored, out)
+}
+
+// put saves a copy of obj under key at a new revision. The caller must hold the write lock.
+func (s *store) put(key string, obj runtime.Object) (runtime.Object, error) {
+	stored := obj.DeepCopyObject()
+	s.revision++
+	if err := s.versioner.UpdateObject(stored, s.revision); err != nil {
+		s.revision--
+		return nil, err
+	}
+	s.objects[key] = &record{obj: stored, revision: s.revision}
+	return stored, nil
+}
+
+// semanticallyEqual compares two objects ignoring their resourceVersion, matching the etcd
+// storage which skips writes that don't change the serialized object.
+func (s *store) semanticallyEqual(existing, updated runtime.Object) (bool, error) {
+	l, r := existing.DeepCopyObject(), updated.DeepCopyObject()
+	if err := s.versioner.PrepareObjectForStorage(l); err != nil {
+		return false, err
+	}
+	if err := s.versioner.PrepareObjectForStorage(r); err != nil {
+		return false, err
+	}
+	return equality.Semantic.DeepEqual(l, r), nil
+}
+
+func (s *store) setList(listObj runtime.Object, items []runtime.Object, next string, remaining *int64) error {
+	if err := meta.SetList(listObj, items); err != nil {
+		return err
+	}
+	return s.versioner.UpdateList(listObj, s.revision, next, remaining)
+}
+
+// continueToken is the continue token of the etcd storage
+type continueToken struct {
+	APIVersion      string `json:"v"`
+	ResourceVersion int64  `json:"rv"`
+	StartKey        string `json:"start"`
+}
+
+// encodeContinue returns the continue token of a list resuming at key
+func encodeContinue(key, prefix string, resourceVersion int64) (string, error) {
+	out, err := json.Marshal(&continueToken{
+		APIVersion:      "meta.k8s.io/v1",
+		ResourceVersion: resourceVersion,
+		StartKey:        strings.TrimPrefix(key, prefix),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// decodeContinue returns the key at which the list of the continue token resumes
+func decodeContinue(continueValue, prefix string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(continueValue)
+	if err != nil {
+		return "", err
+	}
+	c := &continueToken{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return "", err
+	}
+	if c.APIVersion != "meta.k8s.io/v1" {
+		return "", fmt.Errorf("unknown continue token version %q", c.APIVersion)
+	}
+	if len(c.StartKey) == 0 {
+		return "", fmt.Errorf("empty start key")
+	}
+	// The start key can't select keys outside of the prefix
+	key := "/" + strings.TrimPrefix(c.StartKey, "/")
+	if path.Clean(key) != key {
+		return "", fmt.Errorf("invalid start key %s", c.StartKey)
+	}
+	return prefix + key[1:], nil
+}
+
+// copyInto copies the value of src into the object pointed to by dst.
+func copyInto(src, dst runtime.Object) error {
+	dstValue, err := conversion.EnforcePtr(dst)
+	if err != nil {
+		return err
+	}
+	srcValue, err := conversion.EnforcePtr(src.DeepCopyObject())
+	if err != nil {
+		return err
+	}
+	if srcValue.Type() != dstValue.Type() {
+		return fmt.Errorf("cannot copy %v into %v", srcValue.Type(), dstValue.Type())
+	}
+	dstValue.Set(reflect.ValueOf(srcValue.Interface()))
+	return nil
+}
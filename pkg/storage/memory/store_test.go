@@ -0,0 +1,560 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/apis/example"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+func newPod(name string, labels map[string]string) *example.Pod {
+	return &example.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		UID:       types.UID(name + "-uid"),
+		Labels:    labels,
+	}}
+}
+
+func podKey(name string) string {
+	return "/pods/default/" + name
+}
+
+// create stores the pod and returns the stored copy
+func create(t *testing.T, s *store, pod *example.Pod) *example.Pod {
+	out := &example.Pod{}
+	if err := s.Create(context.TODO(), podKey(pod.Name), pod, out, 0); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	return out
+}
+
+// update sets the labels of the pod and returns the stored copy
+func update(t *testing.T, s *store, name string, labels map[string]string) *example.Pod {
+	out := &example.Pod{}
+	err := s.GuaranteedUpdate(context.TODO(), podKey(name), out, false, nil,
+		func(obj runtime.Object, _ storage.ResponseMeta) (runtime.Object, *uint64, error) {
+			pod := obj.(*example.Pod)
+			pod.Labels = labels
+			return pod, nil, nil
+		})
+	if err != nil {
+		t.Fatalf("GuaranteedUpdate() failed: %v", err)
+	}
+	return out
+}
+
+func remove(t *testing.T, s *store, name string) {
+	if err := s.Delete(context.TODO(), podKey(name), &example.Pod{}, nil, storage.ValidateAllObjectFunc); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	s := newStore()
+	out := create(t, s, newPod("foo", nil))
+	if out.ResourceVersion != "2" {
+		t.Errorf("Create() resourceVersion = %q, want 2", out.ResourceVersion)
+	}
+
+	err := s.Create(context.TODO(), podKey("foo"), newPod("foo", nil), &example.Pod{}, 0)
+	if !storage.IsNodeExist(err) {
+		t.Errorf("Create() of an existing key = %v, want a key exists error", err)
+	}
+	err = s.Create(context.TODO(), podKey("bar"), out, &example.Pod{}, 0)
+	if err == nil {
+		t.Errorf("Create() of an object with a resourceVersion succeeded, want an error")
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := newStore()
+	created := create(t, s, newPod("foo", nil))
+
+	out := &example.Pod{}
+	if err := s.Get(context.TODO(), podKey("foo"), "", out, false); err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if !reflect.DeepEqual(out, created) {
+		t.Errorf("Get() = %v, want %v", out, created)
+	}
+	// The stored object is a copy
+	out.Labels = map[string]string{"changed": "true"}
+	if err := s.Get(context.TODO(), podKey("foo"), "", out, false); err != nil || len(out.Labels) > 0 {
+		t.Errorf("Get() = %v, %v, want the stored object unchanged", out, err)
+	}
+
+	if err := s.Get(context.TODO(), podKey("bar"), "", out, false); !storage.IsNotFound(err) {
+		t.Errorf("Get() of a missing key = %v, want a not found error", err)
+	}
+	if err := s.Get(context.TODO(), podKey("bar"), "", out, true); err != nil || len(out.Name) > 0 {
+		t.Errorf("Get() of a missing key = %v, %v, want the zero value", out, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	wrongUID := types.UID("wrong")
+	wrongVersion := "1"
+	tests := []struct {
+		name          string
+		key           string
+		preconditions *storage.Preconditions
+		validate      storage.ValidateObjectFunc
+		wantErr       func(error) bool
+	}{
+		{
+			name:     "delete",
+			key:      podKey("foo"),
+			validate: storage.ValidateAllObjectFunc,
+		},
+		{
+			name:          "matching preconditions",
+			key:           podKey("foo"),
+			preconditions: storage.NewUIDPreconditions("foo-uid"),
+			validate:      storage.ValidateAllObjectFunc,
+		},
+		{
+			name:          "uid precondition",
+			key:           podKey("foo"),
+			preconditions: &storage.Preconditions{UID: &wrongUID},
+			validate:      storage.ValidateAllObjectFunc,
+			wantErr:       storage.IsInvalidObj,
+		},
+		{
+			name:          "resourceVersion precondition",
+			key:           podKey("foo"),
+			preconditions: &storage.Preconditions{ResourceVersion: &wrongVersion},
+			validate:      storage.ValidateAllObjectFunc,
+			wantErr:       storage.IsInvalidObj,
+		},
+		{
+			name: "deletion validation",
+			key:  podKey("foo"),
+			validate: func(ctx context.Context, obj runtime.Object) error {
+				return fmt.Errorf("forbidden")
+			},
+			wantErr: func(err error) bool { return err.Error() == "forbidden" },
+		},
+		{
+			name:     "missing key",
+			key:      podKey("bar"),
+			validate: storage.ValidateAllObjectFunc,
+			wantErr:  storage.IsNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore()
+			created := create(t, s, newPod("foo", nil))
+
+			out := &example.Pod{}
+			err := s.Delete(context.TODO(), tt.key, out, tt.preconditions, tt.validate)
+			if tt.wantErr != nil {
+				if err == nil || !tt.wantErr(err) {
+					t.Fatalf("Delete() = %v, want an error", err)
+				}
+				if err := s.Get(context.TODO(), podKey("foo"), "", &example.Pod{}, false); err != nil {
+					t.Errorf("Get() after a failed delete = %v, want the object kept", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Delete() failed: %v", err)
+			}
+			if !reflect.DeepEqual(out, created) {
+				t.Errorf("Delete() = %v, want %v", out, created)
+			}
+			if err := s.Get(context.TODO(), podKey("foo"), "", &example.Pod{}, false); !storage.IsNotFound(err) {
+				t.Errorf("Get() after delete = %v, want a not found error", err)
+			}
+		})
+	}
+}
+
+func TestGuaranteedUpdateConflict(t *testing.T) {
+	s := newStore()
+	create(t, s, newPod("foo", nil))
+
+	calls := 0
+	out := &example.Pod{}
+	err := s.GuaranteedUpdate(context.TODO(), podKey("foo"), out, false, nil,
+		func(obj runtime.Object, _ storage.ResponseMeta) (runtime.Object, *uint64, error) {
+			calls++
+			pod := obj.(*example.Pod)
+			if calls == 1 {
+				// Another writer updates the object before this update is committed
+				update(t, s, "foo", map[string]string{"writer": "other"})
+			}
+			if pod.Labels == nil {
+				pod.Labels = map[string]string{}
+			}
+			pod.Labels["writer"+fmt.Sprint(calls)] = "this"
+			return pod, nil, nil
+		})
+	if err != nil {
+		t.Fatalf("GuaranteedUpdate() failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("GuaranteedUpdate() tried %d updates, want a retry after the conflict", calls)
+	}
+	want := map[string]string{"writer": "other", "writer2": "this"}
+	if !reflect.DeepEqual(out.Labels, want) {
+		t.Errorf("GuaranteedUpdate() labels = %v, want %v", out.Labels, want)
+	}
+	if out.ResourceVersion != "4" {
+		t.Errorf("GuaranteedUpdate() resourceVersion = %q, want 4", out.ResourceVersion)
+	}
+}
+
+func TestGuaranteedUpdateNoop(t *testing.T) {
+	s := newStore()
+	created := create(t, s, newPod("foo", map[string]string{"app": "web"}))
+	w, err := s.Watch(context.TODO(), podKey("foo"), created.ResourceVersion, storage.Everything)
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Stop()
+
+	out := update(t, s, "foo", map[string]string{"app": "web"})
+	if out.ResourceVersion != created.ResourceVersion {
+		t.Errorf("GuaranteedUpdate() resourceVersion = %q, want %q unchanged", out.ResourceVersion, created.ResourceVersion)
+	}
+	if s.revision != 2 {
+		t.Errorf("revision = %d, want 2 unchanged", s.revision)
+	}
+	expectNoEvent(t, w)
+}
+
+func TestGuaranteedUpdatePreconditions(t *testing.T) {
+	s := newStore()
+	create(t, s, newPod("foo", nil))
+	err := s.GuaranteedUpdate(context.TODO(), podKey("foo"), &example.Pod{}, false, storage.NewUIDPreconditions("wrong"),
+		func(obj runtime.Object, _ storage.ResponseMeta) (runtime.Object, *uint64, error) {
+			t.Errorf("tryUpdate called despite the failed preconditions")
+			return obj, nil, nil
+		})
+	if !storage.IsInvalidObj(err) {
+		t.Errorf("GuaranteedUpdate() = %v, want an invalid object error", err)
+	}
+}
+
+func TestListPaging(t *testing.T) {
+	s := newStore()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		labels := map[string]string{"app": "web"}
+		if name == "c" {
+			labels["app"] = "db"
+		}
+		create(t, s, newPod(name, labels))
+	}
+	// Objects of other namespaces aren't listed
+	other := newPod("a", nil)
+	other.Namespace = "other"
+	if err := s.Create(context.TODO(), "/pods/other/a", other, &example.Pod{}, 0); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	list := func(p storage.SelectionPredicate) *example.PodList {
+		out := &example.PodList{}
+		if err := s.List(context.TODO(), "/pods/default", "", p, out); err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+		return out
+	}
+	names := func(l *example.PodList) []string {
+		result := []string{}
+		for _, pod := range l.Items {
+			result = append(result, pod.Name)
+		}
+		return result
+	}
+
+	all := list(storage.Everything)
+	if got := names(all); !reflect.DeepEqual(got, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("List() = %v, want every pod of the namespace", got)
+	}
+	if len(all.Continue) > 0 {
+		t.Errorf("List() continue = %q, want none without a limit", all.Continue)
+	}
+
+	p := storage.Everything
+	p.Limit = 2
+	pages := [][]string{}
+	remaining := []int64{}
+	for {
+		page := list(p)
+		pages = append(pages, names(page))
+		if page.RemainingItemCount != nil {
+			remaining = append(remaining, *page.RemainingItemCount)
+		}
+		if len(page.Continue) == 0 {
+			break
+		}
+		p.Continue = page.Continue
+	}
+	if want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("List() pages = %v, want %v", pages, want)
+	}
+	if want := []int64{3, 1}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("List() remaining items = %v, want %v", remaining, want)
+	}
+
+	// The pages are filled with the objects matching the selector
+	p = storage.SelectionPredicate{
+		Label:    labels.SelectorFromSet(labels.Set{"app": "web"}),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+		Limit:    3,
+	}
+	page := list(p)
+	if got := names(page); !reflect.DeepEqual(got, []string{"a", "b", "d"}) {
+		t.Errorf("List() = %v, want the first 3 web pods", got)
+	}
+	if page.RemainingItemCount != nil {
+		t.Errorf("List() remaining items = %d, want none with a selector", *page.RemainingItemCount)
+	}
+	p.Continue = page.Continue
+	if got := names(list(p)); !reflect.DeepEqual(got, []string{"e"}) {
+		t.Errorf("List() = %v, want the last web pod", got)
+	}
+
+	p = storage.Everything
+	p.Continue = "invalid"
+	if err := s.List(context.TODO(), "/pods/default", "", p, &example.PodList{}); !apierrors.IsBadRequest(err) {
+		t.Errorf("List() with an invalid continue token = %v, want a bad request", err)
+	}
+	p.Continue = page.Continue
+	if err := s.List(context.TODO(), "/pods/default", "5", p, &example.PodList{}); !apierrors.IsBadRequest(err) {
+		t.Errorf("List() with a continue token and a resourceVersion = %v, want a bad request", err)
+	}
+	// The start key of the token can't leave the listed prefix
+	p.Continue, _ = encodeContinue("/pods/default/../other/a", "/pods/default/", 1)
+	if err := s.List(context.TODO(), "/pods/default", "", p, &example.PodList{}); !apierrors.IsBadRequest(err) {
+		t.Errorf("List() with a continue token outside of the prefix = %v, want a bad request", err)
+	}
+}
+
+// nextEvent returns the next event of the watcher
+func nextEvent(t *testing.T, w watch.Interface) watch.Event {
+	t.Helper()
+	select {
+	case e, ok := <-w.ResultChan():
+		if !ok {
+			t.Fatalf("watch closed, want an event")
+		}
+		return e
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("timed out waiting for an event")
+	}
+	return watch.Event{}
+}
+
+func expectNoEvent(t *testing.T, w watch.Interface) {
+	t.Helper()
+	select {
+	case e := <-w.ResultChan():
+		t.Errorf("got event %s %v, want none", e.Type, e.Object)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// expectEvents checks the type, name and labels of the next events of the watcher
+func expectEvents(t *testing.T, w watch.Interface, want ...string) {
+	t.Helper()
+	for _, expected := range want {
+		e := nextEvent(t, w)
+		pod, ok := e.Object.(*example.Pod)
+		if !ok {
+			t.Fatalf("got event %s %v, want %s", e.Type, e.Object, expected)
+		}
+		if got := fmt.Sprintf("%s %s %v", e.Type, pod.Name, pod.Labels); got != expected {
+			t.Errorf("got event %s, want %s", got, expected)
+		}
+	}
+}
+
+func TestWatchReplay(t *testing.T) {
+	s := newStore()
+	created := create(t, s, newPod("foo", nil))
+	update(t, s, "foo", map[string]string{"app": "web"})
+	create(t, s, newPod("bar", nil))
+	remove(t, s, "foo")
+
+	w, err := s.WatchList(context.TODO(), "/pods/default", created.ResourceVersion, storage.Everything)
+	if err != nil {
+		t.Fatalf("WatchList() failed: %v", err)
+	}
+	defer w.Stop()
+	expectEvents(t, w, "MODIFIED foo map[app:web]", "ADDED bar map[]", "DELETED foo map[app:web]")
+
+	// Live events follow the replayed events
+	update(t, s, "bar", map[string]string{"app": "db"})
+	expectEvents(t, w, "MODIFIED bar map[app:db]")
+	expectNoEvent(t, w)
+}
+
+func TestWatchCurrentState(t *testing.T) {
+	s := newStore()
+	create(t, s, newPod("foo", nil))
+	create(t, s, newPod("bar", nil))
+	remove(t, s, "foo")
+
+	w, err := s.WatchList(context.TODO(), "/pods/default", "0", storage.Everything)
+	if err != nil {
+		t.Fatalf("WatchList() failed: %v", err)
+	}
+	defer w.Stop()
+	expectEvents(t, w, "ADDED bar map[]")
+	expectNoEvent(t, w)
+}
+
+func TestWatchExpired(t *testing.T) {
+	s := newStore()
+	created := create(t, s, newPod("foo", nil))
+	// The history keeps the last historySize events, the first update is trimmed too
+	for i := 0; i <= historySize; i++ {
+		update(t, s, "foo", map[string]string{"update": fmt.Sprint(i)})
+	}
+
+	w, err := s.Watch(context.TODO(), podKey("foo"), created.ResourceVersion, storage.Everything)
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Stop()
+	e := nextEvent(t, w)
+	status, ok := e.Object.(*metav1.Status)
+	if e.Type != watch.Error || !ok || status.Reason != metav1.StatusReasonExpired {
+		t.Errorf("got event %s %v, want a resource expired error", e.Type, e.Object)
+	}
+	if _, ok := <-w.ResultChan(); ok {
+		t.Errorf("got an event after the error, want the watch closed")
+	}
+
+	// The history still covers the revisions after the trimmed events
+	w, err = s.Watch(context.TODO(), podKey("foo"), fmt.Sprint(s.revision-1), storage.Everything)
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Stop()
+	expectEvents(t, w, fmt.Sprintf("MODIFIED foo map[update:%d]", historySize))
+}
+
+func TestWatchPredicate(t *testing.T) {
+	s := newStore()
+	p := storage.SelectionPredicate{
+		Label:    labels.SelectorFromSet(labels.Set{"app": "web"}),
+		Field:    fields.Everything(),
+		GetAttrs: storage.DefaultNamespaceScopedAttr,
+	}
+	w, err := s.WatchList(context.TODO(), "/pods/default", "1", p)
+	if err != nil {
+		t.Fatalf("WatchList() failed: %v", err)
+	}
+	defer w.Stop()
+
+	create(t, s, newPod("foo", map[string]string{"app": "web"}))
+	expectEvents(t, w, "ADDED foo map[app:web]")
+
+	// Updates moving the object out of the selector are deletions, and back in additions
+	update(t, s, "foo", map[string]string{"app": "db"})
+	expectEvents(t, w, "DELETED foo map[app:web]")
+	update(t, s, "foo", map[string]string{"app": "db", "tier": "backend"})
+	expectNoEvent(t, w)
+	update(t, s, "foo", map[string]string{"app": "web"})
+	expectEvents(t, w, "ADDED foo map[app:web]")
+	update(t, s, "foo", map[string]string{"app": "web", "tier": "frontend"})
+	expectEvents(t, w, "MODIFIED foo map[app:web tier:frontend]")
+
+	create(t, s, newPod("bar", map[string]string{"app": "db"}))
+	remove(t, s, "bar")
+	expectNoEvent(t, w)
+
+	remove(t, s, "foo")
+	e := nextEvent(t, w)
+	if pod, ok := e.Object.(*example.Pod); e.Type != watch.Deleted || !ok || pod.ResourceVersion != fmt.Sprint(s.revision) {
+		t.Errorf("got event %s %v, want the deletion at revision %d", e.Type, e.Object, s.revision)
+	}
+}
+
+func TestWatchStop(t *testing.T) {
+	s := newStore()
+	ctx, cancel := context.WithCancel(context.TODO())
+	w, err := s.Watch(ctx, podKey("foo"), "1", storage.Everything)
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	cancel()
+	expectClosed(t, w)
+	if watchers := watcherCount(s); watchers != 0 {
+		t.Errorf("got %d watchers, want the stopped watcher removed", watchers)
+	}
+}
+
+func TestUnresponsiveWatcher(t *testing.T) {
+	s := newStore()
+	create(t, s, newPod("foo", nil))
+	w, err := s.Watch(context.TODO(), podKey("foo"), "0", storage.Everything)
+	if err != nil {
+		t.Fatalf("Watch() failed: %v", err)
+	}
+	defer w.Stop()
+
+	// The watcher holds one event waiting to be received and a full queue, the next event closes it
+	for i := 0; i < watcherQueueSize+2; i++ {
+		update(t, s, "foo", map[string]string{"update": fmt.Sprint(i)})
+	}
+	if watchers := watcherCount(s); watchers != 0 {
+		t.Errorf("got %d watchers, want the unresponsive watcher removed", watchers)
+	}
+	expectClosed(t, w)
+}
+
+func watcherCount(s *store) int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return len(s.watchers)
+}
+
+// expectClosed drains the events of the watcher until it is closed
+func expectClosed(t *testing.T, w watch.Interface) {
+	t.Helper()
+	timeout := time.After(wait.ForeverTestTimeout)
+	for {
+		select {
+		case _, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for the watch to close")
+		}
+	}
+}
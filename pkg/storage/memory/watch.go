@@ -0,0 +1,276 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/klog"
+)
+
+// watcherQueueSize is the number of pending events after which an unresponsive watcher is closed
+const watcherQueueSize = 100
+
+// event is a change to a single key of the store
+type event struct {
+	key      string
+	obj      runtime.Object
+	prevObj  runtime.Object
+	revision uint64
+
+	isCreated bool
+	isDeleted bool
+}
+
+type watcher struct {
+	store     *store
+	id        int
+	key       string
+	recursive bool
+	predicate storage.SelectionPredicate
+
+	incoming chan *event
+	result   chan watch.Event
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ watch.Interface = &watcher{}
+
+func (s *store) watch(ctx context.Context, key string, resourceVersion string, p storage.SelectionPredicate, recursive bool) (watch.Interface, error) {
+	revision, err := s.versioner.ParseResourceVersion(resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	if recursive && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	w := &watcher{
+		store:     s,
+		id:        s.nextID,
+		key:       key,
+		recursive: recursive,
+		predicate: p,
+		incoming:  make(chan *event, watcherQueueSize),
+		result:    make(chan watch.Event),
+		done:      make(chan struct{}),
+	}
+	s.nextID++
+
+	initial, err := s.initialEvents(w, revision)
+	if err != nil {
+		// The requested resourceVersion is no longer available, report it the same way etcd
+		// does for a compacted revision
+		go w.fail(err)
+		return w, nil
+	}
+	s.watchers[w.id] = w
+
+	go w.run(initial)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.Stop()
+		case <-w.done:
+		}
+	}()
+	return w, nil
+}
+
+// initialEvents returns the events to send before live events. A zero revision replays the
+// current state of the watched keys as ADDED events, otherwise the history after revision is
+// replayed. The caller must hold the lock.
+func (s *store) initialEvents(w *watcher, revision uint64) ([]*event, error) {
+	events := []*event{}
+	if revision == 0 {
+		keys := []string{}
+		for k := range s.objects {
+			if w.watches(k) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			r := s.objects[k]
+			events = append(events, &event{key: k, obj: r.obj, revision: r.revision, isCreated: true})
+		}
+		return events, nil
+	}
+
+	if len(s.history) > 0 && revision < s.history[0].revision-1 {
+		return nil, apierrors.NewResourceExpired(fmt.Sprintf(
+			"too old resource version: %d (%d)", revision, s.history[0].revision-1))
+	}
+	for _, e := range s.history {
+		if e.revision > revision && w.watches(e.key) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// notify records the event in the history and dispatches it to the watchers. Watchers that
+// can't keep up are closed so that their clients start over. The caller must hold the lock.
+func (s *store) notify(e *event) {
+	s.history = append(s.history, e)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+
+	for id, w := range s.watchers {
+		if !w.watches(e.key) {
+			continue
+		}
+		select {
+		case w.incoming <- e:
+		default:
+			klog.Warningf("closing unresponsive watcher on %s", w.key)
+			delete(s.watchers, id)
+			w.close()
+		}
+	}
+}
+
+func (w *watcher) watches(key string) bool {
+	if w.recursive {
+		return strings.HasPrefix(key, w.key)
+	}
+	return key == w.key
+}
+
+func (w *watcher) run(initial []*event) {
+	defer close(w.result)
+	for _, e := range initial {
+		if !w.send(e) {
+			return
+		}
+	}
+	for {
+		select {
+		case e := <-w.incoming:
+			if !w.send(e) {
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// send delivers the event to the client if it passes the predicate. It returns false if the
+// watcher has been stopped.
+func (w *watcher) send(e *event) bool {
+	result, err := w.transform(e)
+	if err != nil {
+		result = &watch.Event{Type: watch.Error, Object: &apierrors.NewInternalError(err).ErrStatus}
+	}
+	if result == nil {
+		return true
+	}
+	select {
+	case w.result <- *result:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// transform converts a store event into the watch event seen by the client according to the
+// predicate, e.g. an update moving an object out of a label selector is seen as a deletion.
+func (w *watcher) transform(e *event) (*watch.Event, error) {
+	var cur, prev runtime.Object
+	curPasses, prevPasses := false, false
+	var err error
+
+	if e.obj != nil {
+		cur = e.obj.DeepCopyObject()
+		if curPasses, err = w.predicate.Matches(cur); err != nil {
+			return nil, err
+		}
+	}
+	if e.prevObj != nil {
+		prev = e.prevObj.DeepCopyObject()
+		// The previous state is reported at the revision of the event
+		if err := w.store.versioner.UpdateObject(prev, e.revision); err != nil {
+			return nil, err
+		}
+		if prevPasses, err = w.predicate.Matches(prev); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case e.isDeleted && prevPasses:
+		return &watch.Event{Type: watch.Deleted, Object: prev}, nil
+	case e.isCreated && curPasses:
+		return &watch.Event{Type: watch.Added, Object: cur}, nil
+	case e.isDeleted || e.isCreated:
+		return nil, nil
+	case curPasses && prevPasses:
+		return &watch.Event{Type: watch.Modified, Object: cur}, nil
+	case curPasses && !prevPasses:
+		return &watch.Event{Type: watch.Added, Object: cur}, nil
+	case !curPasses && prevPasses:
+		return &watch.Event{Type: watch.Deleted, Object: prev}, nil
+	}
+	return nil, nil
+}
+
+// fail sends a single error event and closes the watcher.
+func (w *watcher) fail(err error) {
+	defer close(w.result)
+	status := &apierrors.NewInternalError(err).ErrStatus
+	if apiStatus, ok := err.(apierrors.APIStatus); ok {
+		s := apiStatus.Status()
+		status = &s
+	}
+	select {
+	case w.result <- watch.Event{Type: watch.Error, Object: status}:
+	case <-w.done:
+	}
+}
+
+// Stop implements watch.Interface.
+func (w *watcher) Stop() {
+	w.store.lock.Lock()
+	delete(w.store.watchers, w.id)
+	w.store.lock.Unlock()
+	w.close()
+}
+
+func (w *watcher) close() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// ResultChan implements watch.Interface.
+func (w *watcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
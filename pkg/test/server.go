@@ -31,10 +31,12 @@ import (
 	"github.com/spf13/cobra"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	genericoptions "k8s.io/apiserver/pkg/server/options"
+	"k8s.io/apiserver/pkg/storage/storagebackend"
 	"k8s.io/client-go/rest"
 	openapi "k8s.io/kube-openapi/pkg/common"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/builders"
 	"sigs.k8s.io/apiserver-builder-alpha/pkg/cmd/server"
+	"sigs.k8s.io/apiserver-builder-alpha/pkg/storage/memory"
 )
 
 type TestEnvironment struct {
@@ -48,6 +50,7 @@ type TestEnvironment struct {
 	EtcdPeerPort      int
 	EtcdPath          string
 	EtcdCmd           *exec.Cmd
+	StorageBackend    string
 	Done              bool
 
 	apiserverready chan *rest.Config
@@ -55,7 +58,14 @@ type TestEnvironment struct {
 }
 
 func NewTestEnvironment(apis []*builders.APIGroupBuilder, openapidefs openapi.GetOpenAPIDefinitions) *TestEnvironment {
+	return NewTestEnvironmentWithStorageBackend(apis, openapidefs, storagebackend.StorageTypeETCD3)
+}
+
+// NewTestEnvironmentWithStorageBackend returns a test environment persisting resources to the
+// storage backend, e.g. memory.StorageTypeMemory runs the apiserver without an etcd process.
+func NewTestEnvironmentWithStorageBackend(apis []*builders.APIGroupBuilder, openapidefs openapi.GetOpenAPIDefinitions, storageBackend string) *TestEnvironment {
 	te := &TestEnvironment{
+		StorageBackend: storageBackend,
 		EtcdPath:       "/registry/test.kubernetes.io",
 		StopServer:     make(chan struct{}),
 		etcdready:      make(chan string),
@@ -74,6 +84,7 @@ func NewTestEnvironment(apis []*builders.APIGroupBuilder, openapidefs openapi.Ge
 
 	options.RecommendedOptions.SecureServing.BindPort = te.ApiserverPort
 	options.RunDelegatedAuth = false
+	options.RecommendedOptions.Etcd.StorageConfig.Type = storageBackend
 	options.RecommendedOptions.Etcd.StorageConfig.Transport.ServerList = []string{
 		fmt.Sprintf("http://localhost:%d", te.EtcdClientPort),
 	}
@@ -120,11 +131,18 @@ func (te *TestEnvironment) getPort() int {
 func (te *TestEnvironment) Stop() {
 	te.Done = true
 	te.StopServer <- struct{}{}
-	te.EtcdCmd.Process.Kill()
+	if te.EtcdCmd != nil {
+		te.EtcdCmd.Process.Kill()
+	}
 }
 
 // Start starts a local Kubernetes server and updates te.ApiserverPort with the port it is listening on
 func (te *TestEnvironment) Start() *rest.Config {
+	if te.StorageBackend == memory.StorageTypeMemory {
+		go te.startApiserver()
+		return <-te.apiserverready
+	}
+
 	go te.startEtcd()
 
 	// Wait for etcd to start
@@ -16,14 +16,315 @@ limitations under the License.
 
 package validators
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/spec"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
 var OpenAPI = OpenAPIValidator{}
 
+// OpenAPIValidator validates objects against the schemas of the OpenAPI document served by the
+// apiserver.
 type OpenAPIValidator struct {
 	OpenAPI string
+
+	definitions spec.Definitions
+	// schemas are the definitions indexed by their x-kubernetes-group-version-kind extension
+	schemas map[schema.GroupVersionKind]*spec.Schema
+	// patterns caches the compiled pattern of the schemas
+	patterns map[string]*regexp.Regexp
 }
 
+// SetSchema parses the OpenAPI v2 document and indexes its definitions by GroupVersionKind.
 func (o *OpenAPIValidator) SetSchema(openapi string) error {
-	var err error
 	o.OpenAPI = openapi
-	return err
+
+	swagger := &spec.Swagger{}
+	if err := json.Unmarshal([]byte(openapi), swagger); err != nil {
+		return fmt.Errorf("failed to parse the openapi schema: %v", err)
+	}
+	o.definitions = swagger.Definitions
+	o.schemas = map[schema.GroupVersionKind]*spec.Schema{}
+	o.patterns = map[string]*regexp.Regexp{}
+
+	for name := range o.definitions {
+		s := o.definitions[name]
+		gvks, err := groupVersionKinds(s)
+		if err != nil {
+			return fmt.Errorf("invalid openapi definition %s: %v", name, err)
+		}
+		for _, gvk := range gvks {
+			o.schemas[gvk] = &s
+		}
+		if err := o.compilePatterns(&s); err != nil {
+			return fmt.Errorf("invalid openapi definition %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateObject validates the versioned object obj against the OpenAPI schema of its kind. Objects
+// of kinds missing from the OpenAPI document are not validated.
+func (o *OpenAPIValidator) ValidateObject(gvk schema.GroupVersionKind, obj runtime.Object) field.ErrorList {
+	s, found := o.schemas[gvk]
+	if !found {
+		return nil
+	}
+	// The object is validated as it is serialized, the unstructured converter doesn't name the
+	// fields without json tags as encoding/json does
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(nil, err)}
+	}
+	u := map[string]interface{}{}
+	if err := utiljson.Unmarshal(b, &u); err != nil {
+		return field.ErrorList{field.InternalError(nil, err)}
+	}
+	// apiVersion and kind are set by the serializer after validation
+	delete(u, "apiVersion")
+	delete(u, "kind")
+	return o.validate(s, u, nil)
+}
+
+func (o *OpenAPIValidator) validate(s *spec.Schema, value interface{}, path *field.Path) field.ErrorList {
+	s, err := o.resolve(s)
+	if err != nil {
+		return field.ErrorList{field.InternalError(path, err)}
+	}
+	// Unset optional values are serialized as null, e.g. a zero metav1.Time
+	if value == nil {
+		return nil
+	}
+
+	errs := field.ErrorList{}
+	for i := range s.AllOf {
+		errs = append(errs, o.validate(&s.AllOf[i], value, path)...)
+	}
+	if !matchesType(s, value) {
+		return append(errs, field.Invalid(path, value, fmt.Sprintf("must be of type %s", strings.Join(s.Type, " or "))))
+	}
+	if len(s.Enum) > 0 {
+		errs = append(errs, validateEnum(s, value, path)...)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, o.validateObject(s, v, path)...)
+	case []interface{}:
+		errs = append(errs, o.validateArray(s, v, path)...)
+	case string:
+		errs = append(errs, o.validateString(s, v, path)...)
+	case int64:
+		errs = append(errs, validateNumber(s, float64(v), value, path)...)
+	case float64:
+		errs = append(errs, validateNumber(s, v, value, path)...)
+	}
+	return errs
+}
+
+func (o *OpenAPIValidator) validateObject(s *spec.Schema, value map[string]interface{}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for _, name := range s.Required {
+		if _, found := value[name]; !found {
+			errs = append(errs, field.Required(path.Child(name), ""))
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if p, found := s.Properties[name]; found {
+			errs = append(errs, o.validate(&p, value[name], path.Child(name))...)
+			continue
+		}
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			errs = append(errs, o.validate(s.AdditionalProperties.Schema, value[name], path.Key(name))...)
+		}
+	}
+	return errs
+}
+
+func (o *OpenAPIValidator) validateArray(s *spec.Schema, value []interface{}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if s.MinItems != nil && int64(len(value)) < *s.MinItems {
+		errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should have at least %d items", *s.MinItems)))
+	}
+	if s.MaxItems != nil && int64(len(value)) > *s.MaxItems {
+		errs = append(errs, field.TooMany(path, len(value), int(*s.MaxItems)))
+	}
+	if s.Items != nil && s.Items.Schema != nil {
+		for i, item := range value {
+			errs = append(errs, o.validate(s.Items.Schema, item, path.Index(i))...)
+		}
+	}
+	return errs
+}
+
+func (o *OpenAPIValidator) validateString(s *spec.Schema, value string, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if s.MinLength != nil && int64(len(value)) < *s.MinLength {
+		errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should be at least %d chars long", *s.MinLength)))
+	}
+	if s.MaxLength != nil && int64(len(value)) > *s.MaxLength {
+		errs = append(errs, field.TooLong(path, value, int(*s.MaxLength)))
+	}
+	if len(s.Pattern) > 0 && !o.patterns[s.Pattern].MatchString(value) {
+		errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should match '%s'", s.Pattern)))
+	}
+	switch s.Format {
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			errs = append(errs, field.Invalid(path, value, "must be a base64 encoded string"))
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			errs = append(errs, field.Invalid(path, value, "must be a RFC3339 date-time"))
+		}
+	}
+	return errs
+}
+
+func validateNumber(s *spec.Schema, number float64, value interface{}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if s.Minimum != nil {
+		if s.ExclusiveMinimum && number <= *s.Minimum {
+			errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should be greater than %v", *s.Minimum)))
+		} else if number < *s.Minimum {
+			errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should be greater than or equal to %v", *s.Minimum)))
+		}
+	}
+	if s.Maximum != nil {
+		if s.ExclusiveMaximum && number >= *s.Maximum {
+			errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should be less than %v", *s.Maximum)))
+		} else if number > *s.Maximum {
+			errs = append(errs, field.Invalid(path, value, fmt.Sprintf("should be less than or equal to %v", *s.Maximum)))
+		}
+	}
+	if s.Format == "int32" && (number < math.MinInt32 || number > math.MaxInt32) {
+		errs = append(errs, field.Invalid(path, value, "must be a 32 bit integer"))
+	}
+	return errs
+}
+
+func validateEnum(s *spec.Schema, value interface{}, path *field.Path) field.ErrorList {
+	supported := []string{}
+	for _, e := range s.Enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return nil
+		}
+		supported = append(supported, fmt.Sprint(e))
+	}
+	return field.ErrorList{field.NotSupported(path, value, supported)}
+}
+
+// matchesType returns true if the value is of one of the schema types. Schemas without a type
+// accept any value.
+func matchesType(s *spec.Schema, value interface{}) bool {
+	if len(s.Type) == 0 {
+		return true
+	}
+	for _, t := range s.Type {
+		switch value.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case int64:
+			// int-or-string values are declared as strings
+			if t == "integer" || t == "number" || s.Format == "int-or-string" {
+				return true
+			}
+		case float64:
+			if t == "number" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolve follows the $ref of the schema to the definitions of the document
+func (o *OpenAPIValidator) resolve(s *spec.Schema) (*spec.Schema, error) {
+	for ref := s.Ref.String(); len(ref) > 0; ref = s.Ref.String() {
+		def, found := o.definitions[strings.TrimPrefix(ref, "#/definitions/")]
+		if !found {
+			return nil, fmt.Errorf("unresolved openapi reference %s", ref)
+		}
+		s = &def
+	}
+	return s, nil
+}
+
+// compilePatterns compiles the patterns of the schema and its nested schemas
+func (o *OpenAPIValidator) compilePatterns(s *spec.Schema) error {
+	if len(s.Pattern) > 0 {
+		if _, found := o.patterns[s.Pattern]; !found {
+			p, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				return err
+			}
+			o.patterns[s.Pattern] = p
+		}
+	}
+	nested := []spec.Schema{}
+	for _, p := range s.Properties {
+		nested = append(nested, p)
+	}
+	nested = append(nested, s.AllOf...)
+	if s.Items != nil && s.Items.Schema != nil {
+		nested = append(nested, *s.Items.Schema)
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		nested = append(nested, *s.AdditionalProperties.Schema)
+	}
+	for i := range nested {
+		if err := o.compilePatterns(&nested[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupVersionKinds returns the kinds of the x-kubernetes-group-version-kind extension
+func groupVersionKinds(s spec.Schema) ([]schema.GroupVersionKind, error) {
+	extension, found := s.Extensions["x-kubernetes-group-version-kind"]
+	if !found {
+		return nil, nil
+	}
+	b, err := json.Marshal(extension)
+	if err != nil {
+		return nil, err
+	}
+	gvks := []schema.GroupVersionKind{}
+	if err := json.Unmarshal(b, &gvks); err != nil {
+		return nil, err
+	}
+	return gvks, nil
 }
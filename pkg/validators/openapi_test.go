@@ -0,0 +1,249 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validators
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const testOpenAPI = `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "v1"},
+  "paths": {},
+  "definitions": {
+    "io.example.v1.Widget": {
+      "type": "object",
+      "required": ["spec"],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.example.v1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "example.io", "kind": "Widget", "version": "v1"}]
+    },
+    "io.example.v1.WidgetSpec": {
+      "type": "object",
+      "required": ["color", "Manual"],
+      "properties": {
+        "color": {"type": "string", "enum": ["red", "green"]},
+        "port": {"type": "string", "format": "int-or-string"},
+        "since": {"type": "string", "format": "date-time"},
+        "data": {"type": "string", "format": "byte"},
+        "replicas": {"type": "integer", "format": "int32"},
+        "ratio": {"type": "number"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+        "Manual": {"type": "boolean"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "creationTimestamp": {"type": "string", "format": "date-time"}
+      }
+    }
+  }
+}`
+
+var widgetKind = schema.GroupVersionKind{Group: "example.io", Version: "v1", Kind: "Widget"}
+
+func newTestValidator(t *testing.T) *OpenAPIValidator {
+	o := &OpenAPIValidator{}
+	if err := o.SetSchema(testOpenAPI); err != nil {
+		t.Fatalf("failed to set the schema: %v", err)
+	}
+	return o
+}
+
+// errorStrings lists the path and type of the errors, e.g. "spec.color: Required value"
+func errorStrings(errs field.ErrorList) []string {
+	result := []string{}
+	for _, err := range errs {
+		result = append(result, fmt.Sprintf("%s: %s", err.Field, err.Type))
+	}
+	return result
+}
+
+func TestOpenAPIValidatorValidateObject(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(obj map[string]interface{})
+		want   []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(obj map[string]interface{}) {},
+			want:   []string{},
+		},
+		{
+			name:   "missing required field",
+			mutate: func(obj map[string]interface{}) { delete(obj, "spec") },
+			want:   []string{"spec: Required value"},
+		},
+		{
+			name:   "missing required field of a referenced definition",
+			mutate: func(obj map[string]interface{}) { delete(specOf(obj), "color") },
+			want:   []string{"spec.color: Required value"},
+		},
+		{
+			name:   "value of a referenced definition",
+			mutate: func(obj map[string]interface{}) { obj["metadata"] = map[string]interface{}{"name": int64(1)} },
+			want:   []string{"metadata.name: Invalid value"},
+		},
+		{
+			name:   "unsupported enum value",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["color"] = "blue" },
+			want:   []string{"spec.color: Unsupported value"},
+		},
+		{
+			name:   "int-or-string integer",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["port"] = int64(80) },
+			want:   []string{},
+		},
+		{
+			name:   "int-or-string string",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["port"] = "http" },
+			want:   []string{},
+		},
+		{
+			name:   "int-or-string boolean",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["port"] = true },
+			want:   []string{"spec.port: Invalid value"},
+		},
+		{
+			name:   "date-time",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["since"] = "2020-01-02T03:04:05Z" },
+			want:   []string{},
+		},
+		{
+			name:   "invalid date-time",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["since"] = "yesterday" },
+			want:   []string{"spec.since: Invalid value"},
+		},
+		{
+			name:   "unset date-time",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["since"] = nil },
+			want:   []string{},
+		},
+		{
+			name:   "byte",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["data"] = "aGVsbG8=" },
+			want:   []string{},
+		},
+		{
+			name:   "invalid byte",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["data"] = "not base64!" },
+			want:   []string{"spec.data: Invalid value"},
+		},
+		{
+			name:   "int32",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["replicas"] = int64(math.MaxInt32) },
+			want:   []string{},
+		},
+		{
+			name:   "int32 out of range",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["replicas"] = int64(math.MaxInt32) + 1 },
+			want:   []string{"spec.replicas: Invalid value"},
+		},
+		{
+			name:   "integer with a fraction",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["replicas"] = 1.5 },
+			want:   []string{"spec.replicas: Invalid value"},
+		},
+		{
+			name:   "integer for a number",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["ratio"] = int64(1) },
+			want:   []string{},
+		},
+		{
+			name: "additional properties",
+			mutate: func(obj map[string]interface{}) {
+				specOf(obj)["labels"] = map[string]interface{}{"a": "b", "c": int64(1)}
+			},
+			want: []string{"spec.labels[c]: Invalid value"},
+		},
+		{
+			name:   "unknown fields",
+			mutate: func(obj map[string]interface{}) { specOf(obj)["unknown"] = int64(1) },
+			want:   []string{},
+		},
+	}
+	o := newTestValidator(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "example.io/v1",
+				"kind":       "Widget",
+				"metadata":   map[string]interface{}{"name": "foo"},
+				"spec":       map[string]interface{}{"color": "red", "Manual": true},
+			}}
+			tt.mutate(obj.Object)
+			if got := errorStrings(o.ValidateObject(widgetKind, obj)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func specOf(obj map[string]interface{}) map[string]interface{} {
+	return obj["spec"].(map[string]interface{})
+}
+
+// testWidget is a typed Widget, its Manual field has no json tag
+type testWidget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec testWidgetSpec `json:"spec"`
+}
+
+type testWidgetSpec struct {
+	Color  string `json:"color"`
+	Manual bool
+}
+
+func (w *testWidget) DeepCopyObject() runtime.Object {
+	c := *w
+	w.ObjectMeta.DeepCopyInto(&c.ObjectMeta)
+	return &c
+}
+
+func TestOpenAPIValidatorValidateTypedObject(t *testing.T) {
+	o := newTestValidator(t)
+	obj := &testWidget{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Spec: testWidgetSpec{Color: "red"}}
+	if errs := o.ValidateObject(widgetKind, obj); len(errs) > 0 {
+		t.Errorf("ValidateObject() = %v, want no errors", errs)
+	}
+}
+
+func TestOpenAPIValidatorUnknownKind(t *testing.T) {
+	o := newTestValidator(t)
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": "invalid"}}
+	if errs := o.ValidateObject(widgetKind.GroupVersion().WithKind("Gadget"), obj); len(errs) > 0 {
+		t.Errorf("ValidateObject() = %v, want no errors", errs)
+	}
+}
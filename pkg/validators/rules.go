@@ -0,0 +1,577 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validators
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Rule is a compiled "+validation:rule" expression, e.g.
+// self.maxStudents >= self.facultySize * 2
+//
+// The expressions use the Go syntax for:
+//   - the self value, and the oldSelf value on update
+//   - the fields of structs by case insensitive Go name, e.g. self.spec.maxStudents, and the keys
+//     of maps: self.labels.app
+//   - the items of slices and the keys of maps: self.parts[0], self.labels["app"]
+//   - int, float, string, true, false and nil literals
+//   - the arithmetic operators + - * / % and the string concatenation with +
+//   - the comparison operators == != < <= > >= and the logical operators && || !
+//   - the functions len(x), has(x.field) and matches(s, "regular expression")
+type Rule struct {
+	// Expression is the source of the rule
+	Expression string
+	// Message is the detail of the error reported when the rule evaluates to false
+	Message string
+
+	expr        ast.Expr
+	usesOldSelf bool
+	// patterns caches the compiled regular expressions of the matches calls
+	patterns map[string]*regexp.Regexp
+}
+
+// ruleFunctions are the functions callable from a rule with their number of arguments
+var ruleFunctions = map[string]int{"len": 1, "has": 1, "matches": 2}
+
+// CompileRule parses the expression of a rule. The message defaults to the expression.
+func CompileRule(expression, message string) (*Rule, error) {
+	expr, err := parser.ParseExpr(expression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rule %q: %v", expression, err)
+	}
+	r := &Rule{Expression: expression, Message: message, expr: expr, patterns: map[string]*regexp.Regexp{}}
+	if len(r.Message) == 0 {
+		r.Message = fmt.Sprintf("failed rule: %s", expression)
+	}
+
+	if err := r.check(expr); err != nil {
+		return nil, fmt.Errorf("invalid rule %q: %v", expression, err)
+	}
+	return r, nil
+}
+
+// check returns an error if the expression uses syntax the rules don't support
+func (r *Rule) check(expr ast.Expr) error {
+	switch n := expr.(type) {
+	case *ast.Ident:
+		switch n.Name {
+		case "self", "true", "false", "nil":
+		case "oldSelf":
+			r.usesOldSelf = true
+		default:
+			return fmt.Errorf("unknown identifier %s, expected self or oldSelf", n.Name)
+		}
+		return nil
+	case *ast.BasicLit:
+		if n.Kind == token.CHAR || n.Kind == token.IMAG {
+			return fmt.Errorf("unsupported literal %s", n.Value)
+		}
+		return nil
+	case *ast.ParenExpr:
+		return r.check(n.X)
+	case *ast.SelectorExpr:
+		// The selected field isn't an identifier of the rule
+		return r.check(n.X)
+	case *ast.IndexExpr:
+		if err := r.check(n.X); err != nil {
+			return err
+		}
+		return r.check(n.Index)
+	case *ast.UnaryExpr:
+		if n.Op != token.NOT && n.Op != token.SUB {
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		return r.check(n.X)
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.REM,
+			token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.LAND, token.LOR:
+		default:
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		if err := r.check(n.X); err != nil {
+			return err
+		}
+		return r.check(n.Y)
+	case *ast.CallExpr:
+		fn, ok := n.Fun.(*ast.Ident)
+		if !ok || ruleFunctions[fn.Name] == 0 {
+			return fmt.Errorf("unknown function %s, expected one of len, has or matches", source(r.Expression, n.Fun))
+		}
+		if len(n.Args) != ruleFunctions[fn.Name] || n.Ellipsis.IsValid() {
+			return fmt.Errorf("%s takes %d arguments", fn.Name, ruleFunctions[fn.Name])
+		}
+		if _, selector := n.Args[0].(*ast.SelectorExpr); fn.Name == "has" && !selector {
+			return fmt.Errorf("has takes a field, e.g. has(self.field)")
+		}
+		if lit, ok := n.Args[len(n.Args)-1].(*ast.BasicLit); fn.Name == "matches" && ok && lit.Kind == token.STRING {
+			pattern, _ := strconv.Unquote(lit.Value)
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return err
+			}
+			r.patterns[pattern] = re
+		}
+		for _, arg := range n.Args {
+			if err := r.check(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported expression %s", source(r.Expression, expr))
+}
+
+// MustCompileRule is like CompileRule but panics if the expression is invalid. It simplifies the
+// initialization of the package variables holding the rules of the generated code.
+func MustCompileRule(expression, message string) *Rule {
+	r, err := CompileRule(expression, message)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// UsesOldSelf returns true if the rule compares the value to the value before an update, such
+// rules are only evaluated on update
+func (r *Rule) UsesOldSelf() bool {
+	return r.usesOldSelf
+}
+
+// Validate evaluates the rule against self, and oldSelf on update. It returns an error at path
+// if the rule evaluates to false or can't be evaluated.
+func (r *Rule) Validate(self, oldSelf interface{}, path *field.Path) field.ErrorList {
+	if r.usesOldSelf && oldSelf == nil {
+		return nil
+	}
+	e := &ruleEvaluation{rule: r, self: reflect.ValueOf(self), oldSelf: reflect.ValueOf(oldSelf)}
+	result, err := e.eval(r.expr)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, badValue(self), fmt.Sprintf("failed to evaluate rule: %v", err))}
+	}
+	valid, ok := result.(bool)
+	if !ok {
+		return field.ErrorList{field.Invalid(path, badValue(self),
+			fmt.Sprintf("rule evaluated to %v instead of true or false", result))}
+	}
+	if !valid {
+		return field.ErrorList{field.Invalid(path, badValue(self), r.Message)}
+	}
+	return nil
+}
+
+// badValue returns the value reported in the errors of a rule. Like the rules of custom resources,
+// structs and maps are reported as "object" and slices as "array" instead of their content.
+func badValue(self interface{}) interface{} {
+	v, ok := value(reflect.ValueOf(self)).(reflect.Value)
+	if !ok {
+		return value(reflect.ValueOf(self))
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return "array"
+	}
+	return "object"
+}
+
+// source returns the source of the node of the expression
+func source(expression string, n ast.Node) string {
+	return expression[n.Pos()-1 : n.End()-1]
+}
+
+// ruleEvaluation evaluates a rule to nil, bool, int64, float64, string values and reflect.Values
+// of the structs, slices and maps of self and oldSelf
+type ruleEvaluation struct {
+	rule          *Rule
+	self, oldSelf reflect.Value
+}
+
+func (e *ruleEvaluation) eval(expr ast.Expr) (interface{}, error) {
+	switch n := expr.(type) {
+	case *ast.ParenExpr:
+		return e.eval(n.X)
+	case *ast.Ident:
+		switch n.Name {
+		case "self":
+			return value(e.self), nil
+		case "oldSelf":
+			return value(e.oldSelf), nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, nil
+	case *ast.BasicLit:
+		switch n.Kind {
+		case token.INT:
+			return strconv.ParseInt(n.Value, 0, 64)
+		case token.FLOAT:
+			return strconv.ParseFloat(n.Value, 64)
+		}
+		return strconv.Unquote(n.Value)
+	case *ast.SelectorExpr:
+		x, err := e.eval(n.X)
+		if err != nil {
+			return nil, err
+		}
+		v, found, err := selectField(x, n.Sel.Name)
+		if err == nil && !found {
+			err = fmt.Errorf("no such field %s in %s", n.Sel.Name, source(e.rule.Expression, n.X))
+		}
+		return v, err
+	case *ast.IndexExpr:
+		return e.index(n)
+	case *ast.CallExpr:
+		return e.call(n)
+	case *ast.UnaryExpr:
+		x, err := e.eval(n.X)
+		if err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case bool:
+			if n.Op == token.NOT {
+				return !x, nil
+			}
+		case int64:
+			if n.Op == token.SUB {
+				return -x, nil
+			}
+		case float64:
+			if n.Op == token.SUB {
+				return -x, nil
+			}
+		}
+		return nil, fmt.Errorf("operator %s is not supported on %s", n.Op, source(e.rule.Expression, n.X))
+	case *ast.BinaryExpr:
+		return e.binary(n)
+	}
+	return nil, fmt.Errorf("unsupported expression %s", source(e.rule.Expression, expr))
+}
+
+// value returns the value of v, with pointers and interfaces dereferenced
+func value(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	}
+	return v
+}
+
+// selectField returns the field of a struct, matched by Go name, case insensitive Go name or json
+// name, or the key of a map
+func selectField(x interface{}, name string) (interface{}, bool, error) {
+	v, ok := x.(reflect.Value)
+	if !ok {
+		return nil, false, fmt.Errorf("can't select field %s of %v", name, x)
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false, fmt.Errorf("can't select field %s of a map without string keys", name)
+		}
+		item := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		return value(item), item.IsValid(), nil
+	case reflect.Struct:
+		f, found := v.Type().FieldByNameFunc(func(field string) bool {
+			return field == name
+		})
+		if !found {
+			f, found = v.Type().FieldByNameFunc(func(field string) bool {
+				return strings.EqualFold(field, name)
+			})
+		}
+		if !found {
+			for i := 0; i < v.NumField(); i++ {
+				tag := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+				if tag == name {
+					f, found = v.Type().Field(i), true
+					break
+				}
+			}
+		}
+		if !found {
+			return nil, false, nil
+		}
+		return value(v.FieldByIndex(f.Index)), true, nil
+	}
+	return nil, false, fmt.Errorf("can't select field %s of a %s", name, v.Kind())
+}
+
+func (e *ruleEvaluation) index(n *ast.IndexExpr) (interface{}, error) {
+	x, err := e.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	i, err := e.eval(n.Index)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := x.(reflect.Value)
+	switch {
+	case ok && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array):
+		index, ok := i.(int64)
+		if !ok {
+			return nil, fmt.Errorf("the index of %s must be an int", source(e.rule.Expression, n.X))
+		}
+		if index < 0 || index >= int64(v.Len()) {
+			return nil, fmt.Errorf("index %d out of range of %s", index, source(e.rule.Expression, n.X))
+		}
+		return value(v.Index(int(index))), nil
+	case ok && v.Kind() == reflect.Map:
+		key, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("the key of %s must be a string", source(e.rule.Expression, n.X))
+		}
+		item, found, err := selectField(x, key)
+		if err == nil && !found {
+			err = fmt.Errorf("no such key %q in %s", key, source(e.rule.Expression, n.X))
+		}
+		return item, err
+	}
+	return nil, fmt.Errorf("can't index %s", source(e.rule.Expression, n.X))
+}
+
+func (e *ruleEvaluation) call(n *ast.CallExpr) (interface{}, error) {
+	switch n.Fun.(*ast.Ident).Name {
+	case "has":
+		// has is true if the field is set to a value other than its zero value
+		selector := n.Args[0].(*ast.SelectorExpr)
+		x, err := e.eval(selector.X)
+		if err != nil {
+			return nil, err
+		}
+		v, found, err := selectField(x, selector.Sel.Name)
+		if xv, ok := x.(reflect.Value); err == nil && !found && ok && xv.Kind() == reflect.Struct {
+			// Only the keys of maps may be missing
+			err = fmt.Errorf("no such field %s in %s", selector.Sel.Name, source(e.rule.Expression, selector.X))
+		}
+		if err != nil || !found || v == nil {
+			return false, err
+		}
+		if rv, ok := v.(reflect.Value); ok {
+			return !rv.IsZero(), nil
+		}
+		return !reflect.ValueOf(v).IsZero(), nil
+	case "len":
+		x, err := e.eval(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case string:
+			return int64(len(x)), nil
+		case reflect.Value:
+			if k := x.Kind(); k == reflect.Slice || k == reflect.Array || k == reflect.Map {
+				return int64(x.Len()), nil
+			}
+		case nil:
+			return int64(0), nil
+		}
+		return nil, fmt.Errorf("len is not supported on %s", source(e.rule.Expression, n.Args[0]))
+	case "matches":
+		x, err := e.eval(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		p, err := e.eval(n.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		s, ok := x.(string)
+		pattern, isString := p.(string)
+		if !ok || !isString {
+			return nil, fmt.Errorf("matches takes a string and a regular expression")
+		}
+		re, found := e.rule.patterns[pattern]
+		if !found {
+			if re, err = regexp.Compile(pattern); err != nil {
+				return nil, err
+			}
+		}
+		return re.MatchString(s), nil
+	}
+	return nil, fmt.Errorf("unknown function %s", source(e.rule.Expression, n.Fun))
+}
+
+func (e *ruleEvaluation) binary(n *ast.BinaryExpr) (interface{}, error) {
+	x, err := e.eval(n.X)
+	if err != nil {
+		return nil, err
+	}
+	// The logical operators short circuit
+	if n.Op == token.LAND || n.Op == token.LOR {
+		left, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a bool", source(e.rule.Expression, n.X))
+		}
+		if left == (n.Op == token.LOR) {
+			return left, nil
+		}
+		y, err := e.eval(n.Y)
+		if err != nil {
+			return nil, err
+		}
+		right, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a bool", source(e.rule.Expression, n.Y))
+		}
+		return right, nil
+	}
+	y, err := e.eval(n.Y)
+	if err != nil {
+		return nil, err
+	}
+	unsupported := fmt.Errorf("operator %s is not supported between %s and %s",
+		n.Op, source(e.rule.Expression, n.X), source(e.rule.Expression, n.Y))
+
+	if n.Op == token.EQL || n.Op == token.NEQ {
+		equal, ok := equals(x, y)
+		if !ok {
+			return nil, unsupported
+		}
+		return equal == (n.Op == token.EQL), nil
+	}
+
+	switch x := x.(type) {
+	case string:
+		y, ok := y.(string)
+		if !ok {
+			return nil, unsupported
+		}
+		switch n.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.LSS:
+			return x < y, nil
+		case token.LEQ:
+			return x <= y, nil
+		case token.GTR:
+			return x > y, nil
+		case token.GEQ:
+			return x >= y, nil
+		}
+	case int64:
+		if y, ok := y.(int64); ok {
+			switch n.Op {
+			case token.ADD:
+				return x + y, nil
+			case token.SUB:
+				return x - y, nil
+			case token.MUL:
+				return x * y, nil
+			case token.QUO, token.REM:
+				if y == 0 {
+					return nil, fmt.Errorf("division by zero in %s", source(e.rule.Expression, n))
+				}
+				if n.Op == token.QUO {
+					return x / y, nil
+				}
+				return x % y, nil
+			}
+		}
+	}
+
+	// Numbers are compared as floats, ints are promoted when mixed with floats
+	a, aok := number(x)
+	b, bok := number(y)
+	if !aok || !bok {
+		return nil, unsupported
+	}
+	switch n.Op {
+	case token.ADD:
+		return a + b, nil
+	case token.SUB:
+		return a - b, nil
+	case token.MUL:
+		return a * b, nil
+	case token.QUO:
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero in %s", source(e.rule.Expression, n))
+		}
+		return a / b, nil
+	case token.LSS:
+		return a < b, nil
+	case token.LEQ:
+		return a <= b, nil
+	case token.GTR:
+		return a > b, nil
+	case token.GEQ:
+		return a >= b, nil
+	}
+	return nil, unsupported
+}
+
+func number(x interface{}) (float64, bool) {
+	switch x := x.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// equals compares scalars by value, numbers across ints and floats, and other values deeply
+func equals(x, y interface{}) (bool, bool) {
+	if a, ok := number(x); ok {
+		b, ok := number(y)
+		return ok && a == b, true
+	}
+	xv, xok := x.(reflect.Value)
+	yv, yok := y.(reflect.Value)
+	switch {
+	case xok && yok:
+		return reflect.DeepEqual(xv.Interface(), yv.Interface()), true
+	case xok || yok:
+		// Structs, slices and maps are only equal to nil if empty
+		if x == nil || y == nil {
+			v := xv
+			if yok {
+				v = yv
+			}
+			return v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0), true
+		}
+		return false, true
+	}
+	return x == y, true
+}
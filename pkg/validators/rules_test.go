@@ -0,0 +1,219 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package validators
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type ruleSpec struct {
+	ID          string
+	Replicas    int32             `json:"replicas"`
+	MaxStudents *int              `json:"max_students,omitempty"`
+	Ratio       float64           `json:"ratio"`
+	Name        string            `json:"name"`
+	Alias       string            `json:"alias_name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Parts       []rulePart        `json:"parts,omitempty"`
+	Main        *rulePart         `json:"main,omitempty"`
+	Part        rulePart          `json:"part"`
+}
+
+type rulePart struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+func TestRuleValidate(t *testing.T) {
+	students := 10
+	spec := ruleSpec{
+		ID:       "a",
+		Replicas: 3,
+		Ratio:    1.5,
+		Name:     "abc",
+		Alias:    "x",
+		Labels:   map[string]string{"app": "web"},
+		Parts:    []rulePart{{Name: "a", Size: 1}, {Name: "b", Size: 2}},
+	}
+	withStudents := spec
+	withStudents.MaxStudents = &students
+	withMain := spec
+	withMain.Main = &rulePart{Name: "main", Size: 2}
+	withPart := spec
+	withPart.Part = rulePart{Name: "part"}
+	empty := ruleSpec{}
+
+	tests := []struct {
+		name    string
+		rule    string
+		self    interface{}
+		oldSelf interface{}
+		// want is a substring of the error detail, empty if the rule holds
+		want string
+	}{
+		{name: "go name", rule: "self.Replicas == 3", self: spec},
+		{name: "case insensitive go name", rule: `self.replicas == 3 && self.id == "a"`, self: spec},
+		{name: "json name", rule: `self.alias_name == "x"`, self: spec},
+		{name: "pointer to struct", rule: "self.replicas == 3", self: &spec},
+		{name: "unknown field", rule: "self.nope == 1", self: spec, want: "no such field nope in self"},
+		{name: "failed rule", rule: "self.replicas > 3", self: spec, want: "failed rule: self.replicas > 3"},
+
+		{name: "nil pointer", rule: "self.maxStudents == nil", self: spec},
+		{name: "set pointer", rule: "self.maxStudents == nil", self: withStudents, want: "failed rule"},
+		{name: "set pointer value", rule: "self.maxStudents == 10", self: withStudents},
+		{name: "nil pointer value", rule: "self.maxStudents > 1", self: spec, want: "operator > is not supported"},
+		{name: "nil struct pointer", rule: "self.main == nil", self: spec},
+		{name: "field of nil struct pointer", rule: "self.main.size == 1", self: spec, want: "can't select field size"},
+		{name: "zero struct", rule: "self.part == nil", self: spec},
+		{name: "non zero struct", rule: "self.part != nil", self: withPart},
+		{name: "empty slice", rule: "self.parts == nil", self: empty},
+		{name: "non empty slice", rule: "self.parts != nil", self: spec},
+		{name: "nil map", rule: "nil == self.labels", self: empty},
+		{name: "non empty map", rule: "self.labels != nil", self: spec},
+
+		{name: "or short circuits", rule: "self.main == nil || self.main.size > 1", self: spec},
+		{name: "or evaluates the right side", rule: "self.main == nil || self.main.size > 1", self: withMain},
+		{name: "and short circuits", rule: "self.main != nil && self.main.size > 1", self: spec, want: "failed rule"},
+		{name: "and evaluates the right side", rule: "self.main != nil && self.main.size > 1", self: withMain},
+		{name: "logical operator on int", rule: "self.replicas && true", self: spec, want: "self.replicas is not a bool"},
+
+		{name: "int arithmetic", rule: "self.replicas * 2 - 1 == 5 && self.replicas % 2 == 1", self: spec},
+		{name: "int division", rule: "self.replicas / 2 == 1", self: spec},
+		{name: "float division", rule: "self.replicas / 2.0 == 1.5", self: spec},
+		{name: "int promoted to float", rule: "self.ratio * 2 == self.replicas && self.ratio < self.replicas", self: spec},
+		{name: "negation", rule: "-self.replicas < 0 && !(self.replicas > 5)", self: spec},
+		{name: "int division by zero", rule: "self.replicas / 0 == 1", self: spec, want: "division by zero in self.replicas / 0"},
+		{name: "int remainder by zero", rule: "self.replicas % 0 == 1", self: spec, want: "division by zero"},
+		{name: "float division by zero", rule: "self.ratio / 0.0 > 1", self: spec, want: "division by zero"},
+		{name: "string concatenation", rule: `self.name + "d" == "abcd" && self.name < "abd"`, self: spec},
+		{name: "mixed types", rule: `self.name + 1 == "abc1"`, self: spec, want: "operator + is not supported"},
+
+		{name: "has set pointer", rule: "has(self.main)", self: withMain},
+		{name: "has nil pointer", rule: "has(self.main)", self: spec, want: "failed rule"},
+		{name: "has zero value", rule: "has(self.name)", self: empty, want: "failed rule"},
+		{name: "has map key", rule: "has(self.labels.app)", self: spec},
+		{name: "has missing map key", rule: "has(self.labels.tier)", self: spec, want: "failed rule"},
+		{name: "has unknown field", rule: "has(self.nope)", self: spec, want: "no such field nope"},
+
+		{name: "len of string", rule: "len(self.name) == 3", self: spec},
+		{name: "len of slice and map", rule: "len(self.parts) == 2 && len(self.labels) == 1", self: spec},
+		{name: "len of nil", rule: "len(self.labels) == 0 && len(self.main) == 0", self: empty},
+		{name: "len of int", rule: "len(self.replicas) == 0", self: spec, want: "len is not supported on self.replicas"},
+
+		{name: "matches", rule: `matches(self.name, "^[a-z]+$")`, self: spec},
+		{name: "does not match", rule: `matches(self.name, "^[0-9]+$")`, self: spec, want: "failed rule"},
+		{name: "matches int", rule: `matches(self.replicas, "3")`, self: spec, want: "matches takes a string"},
+
+		{name: "slice index", rule: `self.parts[1].name == "b"`, self: spec},
+		{name: "slice index out of range", rule: `self.parts[2].name == "b"`, self: spec, want: "index 2 out of range"},
+		{name: "map key", rule: `self.labels["app"] == "web"`, self: spec},
+		{name: "missing map key", rule: `self.labels["tier"] == "web"`, self: spec, want: `no such key "tier"`},
+
+		{name: "not a bool", rule: "self.replicas", self: spec, want: "rule evaluated to 3 instead of true or false"},
+
+		{name: "oldSelf skipped on create", rule: "self.replicas >= oldSelf.replicas", self: spec},
+		{name: "oldSelf on update", rule: "self.replicas >= oldSelf.replicas", self: spec, oldSelf: withMain},
+		{name: "oldSelf violated on update", rule: "self.replicas >= oldSelf.replicas", self: empty, oldSelf: spec,
+			want: "failed rule"},
+		{name: "self only on update", rule: "self.replicas == 3", self: spec, oldSelf: empty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := CompileRule(tt.rule, "")
+			if err != nil {
+				t.Fatalf("CompileRule() failed: %v", err)
+			}
+			errs := r.Validate(tt.self, tt.oldSelf, field.NewPath("spec"))
+			switch {
+			case len(tt.want) == 0 && len(errs) > 0:
+				t.Errorf("Validate() = %v, want no errors", errs)
+			case len(tt.want) > 0 && len(errs) != 1:
+				t.Errorf("Validate() = %v, want an error containing %q", errs, tt.want)
+			case len(tt.want) > 0 && !strings.Contains(errs[0].Detail, tt.want):
+				t.Errorf("Validate() = %v, want an error containing %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleValidateError(t *testing.T) {
+	tests := []struct {
+		name string
+		self interface{}
+		want interface{}
+	}{
+		{name: "int", self: 3, want: int64(3)},
+		{name: "pointer to string", self: stringPointer("abc"), want: "abc"},
+		{name: "struct", self: rulePart{}, want: "object"},
+		{name: "pointer to struct", self: &rulePart{}, want: "object"},
+		{name: "map", self: map[string]string{}, want: "object"},
+		{name: "slice", self: []rulePart{}, want: "array"},
+	}
+	r := MustCompileRule("false", "never valid")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := field.ErrorList{field.Invalid(field.NewPath("spec"), tt.want, "never valid")}
+			if errs := r.Validate(tt.self, nil, field.NewPath("spec")); !reflect.DeepEqual(errs, want) {
+				t.Errorf("Validate() = %#v, want %#v", errs, want)
+			}
+		})
+	}
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		// want is a substring of the error, empty if the rule is valid
+		want        string
+		usesOldSelf bool
+	}{
+		{rule: `self.a >= oldSelf.a`, usesOldSelf: true},
+		{rule: `has(self.a) && len(self.b) > 0 || matches(self.c, "^[a-z]+$")`},
+		{rule: `self.a == (`, want: "failed to parse rule"},
+		{rule: `spec.a == 1`, want: "unknown identifier spec"},
+		{rule: `self.a & 1 == 1`, want: "unsupported operator &"},
+		{rule: `^self.a == 1`, want: "unsupported operator ^"},
+		{rule: `self.a == 'c'`, want: "unsupported literal 'c'"},
+		{rule: `size(self.a) == 1`, want: "unknown function size"},
+		{rule: `self.a.size() == 1`, want: "unknown function self.a.size"},
+		{rule: `len(self.a, self.b) == 1`, want: "len takes 1 arguments"},
+		{rule: `has(self)`, want: "has takes a field"},
+		{rule: `matches(self.a, "[")`, want: "missing closing ]"},
+		{rule: `self.a[1:2] == nil`, want: "unsupported expression self.a[1:2]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := CompileRule(tt.rule, "")
+			switch {
+			case len(tt.want) == 0 && err != nil:
+				t.Errorf("CompileRule() failed: %v", err)
+			case len(tt.want) > 0 && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("CompileRule() = %v, want an error containing %q", err, tt.want)
+			case err == nil && r.UsesOldSelf() != tt.usesOldSelf:
+				t.Errorf("UsesOldSelf() = %v, want %v", r.UsesOldSelf(), tt.usesOldSelf)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package version holds the build metadata of the apiserver served on /version. The variables
+// are set with -ldflags, e.g.
+//
+//	go build -ldflags "-X sigs.k8s.io/apiserver-builder-alpha/pkg/version.gitCommit=$(git rev-parse HEAD)"
+//
+// which `apiserver-boot build executables` does for the apiserver.
+package version
+
+import (
+	"fmt"
+	"runtime"
+
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+var (
+	gitCommit    = ""                     // sha1 from git, output of $(git rev-parse HEAD)
+	gitTreeState = ""                     // state of git tree, either "clean" or "dirty"
+	buildDate    = "1970-01-01T00:00:00Z" // build date in ISO8601 format, output of $(date -u +'%Y-%m-%dT%H:%M:%SZ')
+)
+
+// Get returns the version information of the apiserver of the given version, e.g. the Version
+// of the StartOptions. Major and Minor are left empty unless the version starts with
+// [v]<major>.<minor>, e.g. v1.2.0.
+func Get(gitVersion string) version.Info {
+	info := version.Info{
+		GitVersion:   gitVersion,
+		GitCommit:    gitCommit,
+		GitTreeState: gitTreeState,
+		BuildDate:    buildDate,
+		GoVersion:    runtime.Version(),
+		Compiler:     runtime.Compiler,
+		Platform:     fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+	}
+	if v, err := utilversion.ParseGeneric(gitVersion); err == nil {
+		info.Major = fmt.Sprint(v.Major())
+		info.Minor = fmt.Sprint(v.Minor())
+	}
+	return info
+}